@@ -0,0 +1,168 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"github.com/astrata/tango/clf"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/gosexy/to"
+	"io"
+	"os"
+	"time"
+)
+
+// Session key under which apps may keep the name of the logged in user, it's
+// written in the access log.
+var SessionUserKey = "user"
+
+// Returns the access log configured under access_log in settings.yaml.
+func accessLogSettings() *clf.Logger {
+	format := to.String(config.Get("access_log/format"))
+
+	switch format {
+	case "", "default":
+		format = clf.Default
+	case "common":
+		format = clf.Common
+	case "common_id":
+		format = clf.CommonID
+	case "combined":
+		format = clf.Combined
+	}
+
+	output := to.String(config.Get("access_log/output"))
+
+	if output == "off" {
+		return nil
+	}
+
+	writer := accessLogOutput(output)
+
+	if size := int(to.Int64(config.Get("access_log/buffer"))); size > 0 {
+		policy := clf.Block
+		if to.String(config.Get("access_log/overflow")) == "drop" {
+			policy = clf.Drop
+		}
+		writer = clf.NewAsyncWriter(writer, size, policy)
+	}
+
+	return clf.New(writer, format)
+}
+
+// Returns the writer for the access_log/output setting.
+func accessLogOutput(output string) io.Writer {
+	switch output {
+	case "", "stdout":
+		return os.Stdout
+	case "stderr":
+		return os.Stderr
+	case "syslog":
+		tag := to.String(config.Get("access_log/syslog_tag"))
+		if tag == "" {
+			tag = "tango"
+		}
+		writer, err := clf.Syslog(tag)
+		if err != nil {
+			logger.Warn("Could not connect to syslog, logging to stdout.", "error", err)
+			return os.Stdout
+		}
+		return writer
+	}
+
+	var interval time.Duration
+
+	switch rotate := to.String(config.Get("access_log/rotate")); rotate {
+	case "":
+	case "hourly":
+		interval = time.Hour
+	case "daily":
+		interval = 24 * time.Hour
+	default:
+		interval = time.Duration(to.Int64(rotate)) * time.Second
+	}
+
+	// Megabytes.
+	maxSize := to.Int64(config.Get("access_log/max_size")) * 1024 * 1024
+
+	file, err := clf.OpenRotatingFile(output, maxSize, interval, to.Bool(config.Get("access_log/compress")))
+
+	if err != nil {
+		logger.Warn("Could not open access log, logging to stdout.", "file", output, "error", err)
+		return os.Stdout
+	}
+
+	clf.ReopenOnSignal(file)
+
+	return file
+}
+
+// Replaces the access log, nil disables it.
+func (s *Server) SetAccessLog(logger *clf.Logger) {
+	s.accessLog = logger
+}
+
+// Returns the user for the access log: the authenticated user or the user
+// kept in a session the handler already loaded. Unverified credentials are
+// never logged.
+func (context *Context) logUser() string {
+	if context.user != "" {
+		return context.user
+	}
+
+	if context.session == nil {
+		return ""
+	}
+
+	user, _ := context.session.Get(SessionUserKey).(string)
+
+	return user
+}
+
+// Logs and measures a finished request.
+func (context *Context) finish(status int, size int) {
+	context.logRequest(status, size)
+	context.observe(status, size)
+}
+
+// Writes the access log line of the request.
+func (context *Context) logRequest(status int, size int) {
+	if context.Server == nil || context.Server.accessLog == nil {
+		return
+	}
+
+	context.Server.accessLog.Log(clf.Entry{
+		Request:    context.Request,
+		RemoteAddr: context.ClientIP(),
+		User:       context.logUser(),
+		Status:     status,
+		Size:       size,
+		Time:       context.started,
+		Duration:   time.Since(context.started),
+		RequestID:  context.requestID,
+	})
+}
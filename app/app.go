@@ -29,7 +29,7 @@ package app
 import (
 	"fmt"
 	"github.com/astrata/tango"
-	"log"
+	"github.com/astrata/tango/logger"
 	"os"
 )
 
@@ -37,6 +37,15 @@ var routes = make(map[string]Model)
 var fallbacks = make(map[string]Model)
 var apps = make(map[string]Model)
 
+type filter struct {
+	path string
+	fn   tango.Filter
+}
+
+var filters = []filter{}
+
+var roles tango.RoleResolver
+
 // Tango! server.
 var Server *tango.Server
 
@@ -47,7 +56,7 @@ type Model interface {
 }
 
 func init() {
-	log.Println("Tango! by Astrata")
+	logger.Info("Tango! by Astrata")
 	fmt.Fprintf(os.Stderr, "\n")
 }
 
@@ -83,21 +92,41 @@ func Fallback(name string, app Model) {
 	fallbacks[name] = app
 }
 
+// Adds a tango.Filter for the given path and its subpaths.
+func Filter(path string, fn tango.Filter) {
+	filters = append(filters, filter{path, fn})
+}
+
+// Sets the function that returns the roles of the current user, see
+// tango.Permissioned.
+func Roles(fn tango.RoleResolver) {
+	roles = fn
+}
+
 // Initializes a fastcgi/http server.
 func Run() {
 
-	log.Println("Initializing server...")
+	logger.Info("Initializing server...")
 
 	Server = tango.NewServer()
 
+	if roles != nil {
+		Server.SetRoleResolver(roles)
+	}
+
+	for _, f := range filters {
+		logger.Info("Adding filter.", "path", f.path)
+		Server.Filter(f.path, f.fn)
+	}
+
 	for route, model := range routes {
-		log.Printf("Adding route: %s\n", route)
+		logger.Info("Adding route.", "path", route)
 		model.StartUp()
 		Server.Connect(route, model)
 	}
 
 	for fallback, model := range fallbacks {
-		log.Printf("Adding fallback: %s\n", fallback)
+		logger.Info("Adding fallback.", "path", fallback)
 		model.StartUp()
 		Server.Connect(fallback, model)
 	}
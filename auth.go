@@ -27,28 +27,94 @@
 package tango
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// How long a Digest nonce is valid.
+var DigestNonceLifetime = 5 * time.Minute
+
 // HTTP Authorizarion struct
 type AuthBasic struct {
 	User     string
 	Password string
 }
 
+// Checks a user name and a password.
+type BasicVerifier func(user string, password string) bool
+
+// Checks a bearer token and returns the name of the user it belongs to.
+type BearerVerifier func(token string) (user string, ok bool)
+
+// Returns the hex encoded H(user:realm:password) of a user for the given
+// algorithm ("MD5" or "SHA-256"), see DigestHA1().
+type DigestLookup func(user string, realm string, algorithm string) (ha1 string, ok bool)
+
+// Compares two strings in constant time.
+func SecureCompare(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Returns a BasicVerifier for a fixed set of user names and passwords.
+func BasicCredentials(users map[string]string) BasicVerifier {
+	return func(user string, password string) bool {
+		expected, ok := users[user]
+		if ok == false {
+			// Spending the same time as a wrong password.
+			SecureCompare(password, password)
+			return false
+		}
+		return SecureCompare(password, expected)
+	}
+}
+
+// Returns a BearerVerifier for a fixed set of tokens, mapped to user names.
+func BearerTokens(tokens map[string]string) BearerVerifier {
+	return func(token string) (string, bool) {
+		for known, user := range tokens {
+			if SecureCompare(token, known) == true {
+				return user, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Splits an Authorization header into scheme and credentials.
+func (context *Context) authorization() (string, string) {
+	val := strings.TrimSpace(context.Request.Header.Get("Authorization"))
+
+	parts := strings.SplitN(val, " ", 2)
+
+	if len(parts) != 2 {
+		return "", ""
+	}
+
+	return strings.ToLower(parts[0]), strings.TrimSpace(parts[1])
+}
+
 // Returns the AuthBasic struct for the current Context.
 func (context *Context) AuthBasic() AuthBasic {
 	login := AuthBasic{}
 
-	val := context.Request.Header.Get("Authorization")
+	scheme, credentials := context.authorization()
 
-	if val != "" {
-		parts := strings.Split(val, " ")
-		if parts[0] == "Basic" {
-			auth, err := base64.StdEncoding.DecodeString(parts[1])
-			if err == nil {
-				chunks := strings.Split(string(auth), ":")
+	if scheme == "basic" {
+		auth, err := base64.StdEncoding.DecodeString(credentials)
+		if err == nil {
+			// Passwords may contain colons, user names can't.
+			chunks := strings.SplitN(string(auth), ":", 2)
+			if len(chunks) == 2 {
 				login.User = chunks[0]
 				login.Password = chunks[1]
 			}
@@ -57,3 +123,328 @@ func (context *Context) AuthBasic() AuthBasic {
 
 	return login
 }
+
+// Returns the token of a "Bearer" Authorization header.
+func (context *Context) AuthBearer() string {
+	scheme, credentials := context.authorization()
+
+	if scheme == "bearer" {
+		return credentials
+	}
+
+	return ""
+}
+
+// Returns the name of the user that was authenticated by a filter.
+func (context *Context) User() string {
+	return context.user
+}
+
+// Sets the name of the authenticated user.
+func (context *Context) SetUser(user string) {
+	context.user = user
+}
+
+// Quotes a parameter of a WWW-Authenticate header.
+func quoteAuthParam(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Returns a Filter that requires HTTP Basic authentication.
+func BasicAuth(realm string, verify BasicVerifier) Filter {
+	return func(context *Context) int {
+		login := context.AuthBasic()
+
+		if login.User != "" && verify(login.User, login.Password) == true {
+			context.SetUser(login.User)
+			return 0
+		}
+
+		context.Writer.Header().Add("WWW-Authenticate", "Basic realm="+quoteAuthParam(realm)+`, charset="UTF-8"`)
+
+		return 401
+	}
+}
+
+// Sends a Bearer challenge, invalid tells the client the token it sent was
+// rejected.
+func bearerChallenge(context *Context, realm string, invalid bool) int {
+	challenge := "Bearer realm=" + quoteAuthParam(realm)
+
+	if invalid == true {
+		challenge += `, error="invalid_token"`
+	}
+
+	context.Writer.Header().Add("WWW-Authenticate", challenge)
+
+	return 401
+}
+
+// Returns a Filter that requires a bearer token (RFC 6750).
+func BearerAuth(realm string, verify BearerVerifier) Filter {
+	return func(context *Context) int {
+		token := context.AuthBearer()
+
+		if token != "" {
+			if user, ok := verify(token); ok == true {
+				context.SetUser(user)
+				return 0
+			}
+		}
+
+		return bearerChallenge(context, realm, token != "")
+	}
+}
+
+// Hash functions for Digest authentication.
+var digestHashes = map[string]func() hash.Hash{
+	"MD5":     md5.New,
+	"SHA-256": sha256.New,
+}
+
+func digestHash(algorithm string, value string) string {
+	h := digestHashes[algorithm]()
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Returns H(user:realm:password) for the given algorithm, for storing Digest
+// credentials without keeping the password.
+func DigestHA1(algorithm string, user string, realm string, password string) string {
+	return digestHash(algorithm, user+":"+realm+":"+password)
+}
+
+var (
+	digestKey     []byte
+	digestKeyOnce sync.Once
+)
+
+// Signs a nonce timestamp.
+func digestNonceMac(timestamp string) string {
+	digestKeyOnce.Do(func() {
+		digestKey = deriveKey(secretKeys()[0], "tango digest nonce")
+	})
+	mac := hmac.New(sha256.New, digestKey)
+	mac.Write([]byte(timestamp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Returns a new nonce, made of a timestamp and its signature so it can be
+// checked without keeping a list of issued nonces.
+func digestNonce() string {
+	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	return timestamp + "." + digestNonceMac(timestamp)
+}
+
+// Checks a nonce, stale is true if it's authentic but too old.
+func checkDigestNonce(nonce string) (valid bool, stale bool) {
+	chunks := strings.SplitN(nonce, ".", 2)
+
+	if len(chunks) != 2 || SecureCompare(digestNonceMac(chunks[0]), chunks[1]) == false {
+		return false, false
+	}
+
+	timestamp, err := strconv.ParseInt(chunks[0], 10, 64)
+
+	if err != nil {
+		return false, false
+	}
+
+	if time.Since(time.Unix(0, timestamp)) > DigestNonceLifetime {
+		return false, true
+	}
+
+	return true, false
+}
+
+// Nonce count last used with a nonce.
+type digestCount struct {
+	nc      uint64
+	expires time.Time
+}
+
+// Nonce counts seen for each nonce, so a captured header can't be replayed.
+var digestCounts = struct {
+	sync.Mutex
+	seen   map[string]digestCount
+	pruned time.Time
+}{seen: map[string]digestCount{}}
+
+// Records the nonce count of a request, returns false if it's not higher
+// than the last count used with the same nonce.
+func useDigestNonce(nonce string, nc string) bool {
+	count, err := strconv.ParseUint(nc, 16, 64)
+
+	if err != nil || count == 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	digestCounts.Lock()
+	defer digestCounts.Unlock()
+
+	// Forgetting nonces that can't be used anymore.
+	if now.Sub(digestCounts.pruned) > DigestNonceLifetime {
+		for key, seen := range digestCounts.seen {
+			if now.After(seen.expires) {
+				delete(digestCounts.seen, key)
+			}
+		}
+		digestCounts.pruned = now
+	}
+
+	seen, ok := digestCounts.seen[nonce]
+
+	if ok == false {
+		seen.expires = now.Add(DigestNonceLifetime)
+	} else if count <= seen.nc {
+		return false
+	}
+
+	seen.nc = count
+	digestCounts.seen[nonce] = seen
+
+	return true
+}
+
+// Parses the comma separated key=value (or key="value") pairs of a Digest
+// Authorization header.
+func parseAuthParams(value string) map[string]string {
+	params := map[string]string{}
+
+	for len(value) > 0 {
+		value = strings.TrimLeft(value, " \t,")
+
+		eq := strings.IndexByte(value, '=')
+
+		if eq < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(value[:eq]))
+		value = strings.TrimLeft(value[eq+1:], " \t")
+
+		var val string
+
+		if strings.HasPrefix(value, `"`) {
+			buf := []byte{}
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				buf = append(buf, value[i])
+			}
+			val = string(buf)
+			if i < len(value) {
+				i++
+			}
+			value = value[i:]
+		} else {
+			end := strings.IndexByte(value, ',')
+			if end < 0 {
+				end = len(value)
+			}
+			val = strings.TrimSpace(value[:end])
+			value = value[end:]
+		}
+
+		params[key] = val
+	}
+
+	return params
+}
+
+// Checks a Digest Authorization header (RFC 7616), returns the user name
+// and whether the nonce was stale.
+func (context *Context) checkDigest(realm string, lookup DigestLookup) (string, bool) {
+	scheme, credentials := context.authorization()
+
+	if scheme != "digest" {
+		return "", false
+	}
+
+	params := parseAuthParams(credentials)
+
+	algorithm := params["algorithm"]
+
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	algorithm = strings.ToUpper(algorithm)
+
+	session := strings.HasSuffix(algorithm, "-SESS")
+	base := strings.TrimSuffix(algorithm, "-SESS")
+
+	if _, ok := digestHashes[base]; ok == false {
+		return "", false
+	}
+
+	user := params["username"]
+
+	if user == "" || params["realm"] != realm || params["qop"] != "auth" || params["nc"] == "" || params["cnonce"] == "" {
+		return "", false
+	}
+
+	if params["uri"] != context.Request.RequestURI {
+		return "", false
+	}
+
+	valid, stale := checkDigestNonce(params["nonce"])
+
+	if valid == false {
+		return "", stale
+	}
+
+	ha1, ok := lookup(user, realm, base)
+
+	if ok == false {
+		return "", false
+	}
+
+	if session == true {
+		ha1 = digestHash(base, ha1+":"+params["nonce"]+":"+params["cnonce"])
+	}
+
+	ha2 := digestHash(base, context.Request.Method+":"+params["uri"])
+
+	expected := digestHash(base, strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+
+	if SecureCompare(strings.ToLower(params["response"]), expected) == false {
+		return "", false
+	}
+
+	if useDigestNonce(params["nonce"], params["nc"]) == false {
+		return "", false
+	}
+
+	return user, false
+}
+
+// Returns a Filter that requires HTTP Digest authentication (RFC 7616) with
+// SHA-256 or MD5. Nonce counts are kept in memory, a request that reuses a
+// count is rejected.
+func DigestAuth(realm string, lookup DigestLookup) Filter {
+	return func(context *Context) int {
+		user, stale := context.checkDigest(realm, lookup)
+
+		if user != "" {
+			context.SetUser(user)
+			return 0
+		}
+
+		nonce := digestNonce()
+
+		for _, algorithm := range []string{"SHA-256", "MD5"} {
+			challenge := fmt.Sprintf("Digest realm=%s, qop=\"auth\", algorithm=%s, nonce=%s", quoteAuthParam(realm), algorithm, quoteAuthParam(nonce))
+			if stale == true {
+				challenge += ", stale=true"
+			}
+			context.Writer.Header().Add("WWW-Authenticate", challenge)
+		}
+
+		return 401
+	}
+}
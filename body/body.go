@@ -41,3 +41,18 @@ type Body interface {
 	// Sets the request contents.
 	Set(interface{})
 }
+
+// An optional interface for bodies that need to look at the incoming request
+// before being written (e.g. for content negotiation). Prepare() is called
+// right after the model method returns and before Header(), Status() or Get().
+type Preparer interface {
+	Prepare(*http.Request)
+}
+
+// An optional interface for bodies that write their contents directly into
+// the response instead of handing a buffer to Get(). Stream() is called after
+// the headers and a 200 status were sent and returns the number of bytes
+// written.
+type Streamer interface {
+	Stream(http.ResponseWriter) (int, error)
+}
@@ -0,0 +1,299 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package body
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/astrata/tango/logger"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval between the comments that are sent to keep idle event streams
+// open.
+var EventsHeartbeat = 15 * time.Second
+
+// Number of events a subscriber may fall behind before being disconnected.
+var EventsBuffer = 64
+
+// A server-sent event.
+type Event struct {
+	// Event ID, the Broker assigns one if it's empty.
+	ID string
+	// Event type, "message" if empty.
+	Event string
+	// Event data, strings are sent as they are and anything else is encoded
+	// as JSON.
+	Data interface{}
+}
+
+// A Broker fans out published events to every connected event stream and
+// keeps a short history that allows clients to resume with Last-Event-ID.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+	history     []Event
+	size        int
+	lastID      uint64
+}
+
+// Returns a Broker that remembers the last n events.
+func NewBroker(n int) *Broker {
+	self := &Broker{}
+	self.subscribers = make(map[chan Event]bool)
+	self.size = n
+	return self
+}
+
+// Sends an event to every subscriber. Subscribers that can't keep up are
+// disconnected, browsers reconnect and resume from the last event they got.
+func (self *Broker) Publish(event Event) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.lastID++
+
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(self.lastID, 10)
+	}
+
+	if self.size > 0 {
+		self.history = append(self.history, event)
+		if len(self.history) > self.size {
+			self.history = self.history[len(self.history)-self.size:]
+		}
+	}
+
+	for ch := range self.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(self.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Returns a channel that receives new events and the events that were
+// published after the one with the given ID.
+func (self *Broker) Subscribe(lastEventID string) (chan Event, []Event) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	ch := make(chan Event, EventsBuffer)
+
+	self.subscribers[ch] = true
+
+	missed := []Event{}
+
+	if lastEventID != "" {
+		for i := range self.history {
+			if self.history[i].ID == lastEventID {
+				missed = append(missed, self.history[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return ch, missed
+}
+
+// Stops sending events to the given channel.
+func (self *Broker) Unsubscribe(ch chan Event) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.subscribers[ch] == true {
+		delete(self.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Returns the number of connected subscribers.
+func (self *Broker) Subscribers() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.subscribers)
+}
+
+type eventsContent struct {
+	status  int
+	header  http.Header
+	broker  *Broker
+	request *http.Request
+}
+
+// Returns a Body that keeps the connection open and sends the events
+// published on the given Broker as text/event-stream.
+func Events(broker *Broker) Body {
+	self := &eventsContent{}
+	self.status = 200
+	self.broker = broker
+	self.header = http.Header{}
+	self.header.Add("Content-type", "text/event-stream")
+	self.header.Add("Cache-Control", "no-cache")
+	// Disables response buffering on nginx.
+	self.header.Add("X-Accel-Buffering", "no")
+	return self
+}
+
+// Keeps the request for reading Last-Event-ID and detecting disconnection.
+func (self *eventsContent) Prepare(req *http.Request) {
+	self.request = req
+}
+
+// Returns the headers to be sent along the request.
+func (self *eventsContent) Header() http.Header {
+	return self.header
+}
+
+// Returns the request HTTP status.
+func (self *eventsContent) Status() int {
+	if self.broker == nil {
+		return 404
+	}
+	return self.status
+}
+
+// Sets the *Broker to read events from.
+func (self *eventsContent) Set(value interface{}) {
+	switch value.(type) {
+	case *Broker:
+		self.broker = value.(*Broker)
+	}
+}
+
+// Event streams can only be written with Stream().
+func (self *eventsContent) Get() []byte {
+	return []byte{}
+}
+
+// Sends events until the client goes away.
+func (self *eventsContent) Stream(w http.ResponseWriter) (int, error) {
+	flusher, ok := w.(http.Flusher)
+
+	if ok == false {
+		return 0, fmt.Errorf("Event streams are not supported by this connection.")
+	}
+
+	if self.request == nil {
+		return 0, fmt.Errorf("Missing request.")
+	}
+
+	ch, missed := self.broker.Subscribe(self.request.Header.Get("Last-Event-ID"))
+	defer self.broker.Unsubscribe(ch)
+
+	counter := &countingWriter{w: w}
+
+	for _, event := range missed {
+		if err := writeEvent(counter, event); err != nil {
+			return counter.n, err
+		}
+	}
+
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(EventsHeartbeat)
+	defer heartbeat.Stop()
+
+	done := self.request.Context().Done()
+
+	for {
+		select {
+		case event, ok := <-ch:
+			if ok == false {
+				// Dropped by the broker.
+				return counter.n, nil
+			}
+			if err := writeEvent(counter, event); err != nil {
+				return counter.n, err
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(counter, ": ping\n\n"); err != nil {
+				return counter.n, err
+			}
+		case <-done:
+			return counter.n, nil
+		}
+		flusher.Flush()
+	}
+}
+
+// Removes line breaks from single line fields.
+func eventField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// Writes an event in the text/event-stream format.
+func writeEvent(w io.Writer, event Event) error {
+	var data []byte
+
+	switch event.Data.(type) {
+	case string:
+		data = []byte(event.Data.(string))
+	case []byte:
+		data = event.Data.([]byte)
+	default:
+		var err error
+		data, err = json.Marshal(event.Data)
+		if err != nil {
+			// Dropping the event, the stream is still usable.
+			logger.Warn("body.Events: dropping event that could not be encoded.", "error", err)
+			return nil
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if event.ID != "" {
+		fmt.Fprintf(buf, "id: %s\n", eventField(event.ID))
+	}
+
+	if event.Event != "" {
+		fmt.Fprintf(buf, "event: %s\n", eventField(event.Event))
+	}
+
+	// A lone "\r" also ends a line in event streams.
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	data = bytes.Replace(data, []byte("\r"), []byte("\n"), -1)
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+
+	buf.WriteString("\n")
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
@@ -0,0 +1,180 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package body
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"data", Event{Data: "hello"}, "data: hello\n\n"},
+		{"id and event", Event{ID: "7", Event: "update", Data: "x"}, "id: 7\nevent: update\ndata: x\n\n"},
+		{"multi-line", Event{Data: "a\nb\r\nc\rd"}, "data: a\ndata: b\ndata: c\ndata: d\n\n"},
+		{"empty data", Event{Data: ""}, "data: \n\n"},
+		{"bytes", Event{Data: []byte("raw")}, "data: raw\n\n"},
+		{"json", Event{Data: map[string]int{"n": 1}}, "data: {\"n\":1}\n\n"},
+		{"line breaks in fields", Event{ID: "1\n2", Event: "a\r\nb", Data: "x"}, "id: 12\nevent: ab\ndata: x\n\n"},
+		{"unencodable", Event{Data: make(chan int)}, ""},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		if err := writeEvent(buf, test.event); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s: got %q, expecting %q.", test.name, buf.String(), test.want)
+		}
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	broker := NewBroker(3)
+
+	for _, data := range []string{"a", "b", "c", "d"} {
+		broker.Publish(Event{Data: data})
+	}
+
+	tests := []struct {
+		lastEventID string
+		want        []string
+	}{
+		{"", []string{}},
+		{"2", []string{"c", "d"}},
+		{"4", []string{}},
+		// Forgotten events can't be resumed.
+		{"1", []string{}},
+	}
+
+	for _, test := range tests {
+		ch, missed := broker.Subscribe(test.lastEventID)
+		broker.Unsubscribe(ch)
+
+		got := []string{}
+		for _, event := range missed {
+			got = append(got, event.Data.(string))
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("Last-Event-ID %q: got %v, expecting %v.", test.lastEventID, got, test.want)
+		}
+	}
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	broker := NewBroker(0)
+
+	ch, _ := broker.Subscribe("")
+
+	for i := 0; i <= EventsBuffer; i++ {
+		broker.Publish(Event{Data: "x"})
+	}
+
+	if broker.Subscribers() != 0 {
+		t.Fatalf("Expecting the slow subscriber to be dropped.")
+	}
+
+	for _ = range ch {
+	}
+}
+
+// A ResponseRecorder that can be read while the stream writes into it.
+type streamRecorder struct {
+	mu sync.Mutex
+	*httptest.ResponseRecorder
+}
+
+func (self *streamRecorder) Write(p []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.ResponseRecorder.Write(p)
+}
+
+func (self *streamRecorder) String() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.Body.String()
+}
+
+func TestEventsStream(t *testing.T) {
+	broker := NewBroker(10)
+	broker.Publish(Event{Data: "old"})
+	broker.Publish(Event{Data: "missed"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+
+	events := Events(broker).(*eventsContent)
+	events.Prepare(req)
+
+	w := &streamRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	done := make(chan bool)
+
+	go func() {
+		events.Stream(w)
+		done <- true
+	}()
+
+	for broker.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	broker.Publish(Event{Event: "new", Data: "live"})
+
+	want := "id: 2\ndata: missed\n\nid: 3\nevent: new\ndata: live\n\n"
+
+	deadline := time.Now().Add(time.Second)
+
+	for w.String() != want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	if w.String() != want {
+		t.Fatalf("Got %q, expecting %q.", w.String(), want)
+	}
+
+	if broker.Subscribers() != 0 {
+		t.Fatalf("Expecting the stream to unsubscribe.")
+	}
+}
@@ -27,30 +27,174 @@
 package body
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
 	"github.com/gosexy/sugar"
-	"log"
+	"github.com/gosexy/to"
+	"io"
 	"net/http"
+	"reflect"
+	"regexp"
 )
 
+// Options for Json() bodies, they can be combined with |.
+type JsonOption int
+
+const (
+	// Indents the output. Clients can also ask for it with ?pretty and it's
+	// enabled by default if json/pretty is true in settings.yaml.
+	JsonPretty JsonOption = 1 << iota
+	// Allows clients to wrap the output with the function named by ?callback
+	// (JSONP). It's enabled by default if json/callback is true in
+	// settings.yaml.
+	JsonCallback
+	// Does not escape <, > and & (they're escaped by default).
+	JsonRawHTML
+	// Encodes slices one element at a time while writing the response, instead
+	// of buffering them. Since the 200 status is already sent when elements
+	// are encoded, an encoding error can only cut the response short.
+	JsonStream
+)
+
+// Maximum length of a JSONP callback name.
+const maxCallbackLength = 128
+
+// Dotted JavaScript identifiers, like "callback" or "jQuery123.done".
+var callbackExpr = regexp.MustCompile(`^[a-zA-Z_$][0-9a-zA-Z_$]*(\.[a-zA-Z_$][0-9a-zA-Z_$]*)*$`)
+
 type jsonContent struct {
-	status  int
-	header  http.Header
-	content []byte
-	data    interface{}
+	status   int
+	header   http.Header
+	content  []byte
+	data     interface{}
+	options  JsonOption
+	callback string
+	rendered bool
 }
 
 // Returns a Body for an JSON response.
-func Json() Body {
+func Json(options ...JsonOption) Body {
 	self := &jsonContent{}
 	self.status = 200
 	self.header = http.Header{}
 	self.header.Add("Content-type", "application/json")
+
+	for _, option := range options {
+		self.options |= option
+	}
+
+	if to.Bool(config.Get("json/pretty")) == true {
+		self.options |= JsonPretty
+	}
+
+	if to.Bool(config.Get("json/callback")) == true {
+		self.options |= JsonCallback
+	}
+
 	return self
 }
 
+// Reads the ?pretty and ?callback parameters of the request.
+func (self *jsonContent) Prepare(req *http.Request) {
+	query := req.URL.Query()
+
+	if _, ok := query["pretty"]; ok {
+		switch query.Get("pretty") {
+		case "0", "false":
+			self.options &^= JsonPretty
+		default:
+			self.options |= JsonPretty
+		}
+	}
+
+	if self.options&JsonCallback != 0 {
+		self.callback = query.Get("callback")
+	}
+
+	self.rendered = false
+}
+
 // Returns the headers to be sent along the request.
 func (self *jsonContent) Header() http.Header {
+	self.render()
+	return self.header
+}
+
+// Returns the request HTTP status.
+func (self *jsonContent) Status() int {
+	self.render()
+	return self.status
+}
+
+// Sets the request contents.
+func (self *jsonContent) Set(value interface{}) {
+	self.data = value
+	self.rendered = false
+}
+
+// Returns the request contents that are going to be written.
+func (self *jsonContent) Get() []byte {
+	self.render()
+
+	if self.streaming() {
+		buf := bytes.NewBuffer(nil)
+		if err := self.write(buf); err != nil {
+			logger.Error("body.Json: could not encode.", "error", err)
+		}
+		return buf.Bytes()
+	}
+
+	return self.content
+}
+
+// Writes the contents into the response.
+func (self *jsonContent) Stream(w http.ResponseWriter) (int, error) {
+	self.render()
+
+	if self.streaming() {
+		counter := &countingWriter{w: w}
+		err := self.write(counter)
+		return counter.n, err
+	}
+
+	return w.Write(self.content)
+}
+
+// Whether the contents are going to be encoded while being written.
+func (self *jsonContent) streaming() bool {
+	if self.options&JsonStream == 0 || self.status != 200 {
+		return false
+	}
+	if self.data == nil {
+		return false
+	}
+	kind := reflect.TypeOf(self.data).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// Sets status, headers and (unless streaming) contents.
+func (self *jsonContent) render() {
+	if self.rendered == true {
+		return
+	}
+
+	self.rendered = true
+	self.status = 200
+	self.content = nil
+
+	if self.callback != "" {
+		if len(self.callback) > maxCallbackLength || callbackExpr.MatchString(self.callback) == false {
+			self.status = 400
+			self.header.Set("Content-type", "text/plain; charset=utf8")
+			self.content = []byte("Invalid callback name.")
+			return
+		}
+		self.header.Set("Content-type", "application/javascript; charset=utf8")
+		self.header.Set("X-Content-Type-Options", "nosniff")
+	}
 
 	switch self.data.(type) {
 	case map[string]interface{}:
@@ -63,26 +207,119 @@ func (self *jsonContent) Header() http.Header {
 		}
 	}
 
-	return self.header
-}
+	if self.streaming() {
+		return
+	}
 
-// Returns the request HTTP status.
-func (self *jsonContent) Status() int {
-	return self.status
+	buf := bytes.NewBuffer(nil)
+
+	err := self.write(buf)
+
+	if err != nil {
+		logger.Error("body.Json: could not encode.", "error", err)
+		self.status = 500
+		self.content = nil
+		return
+	}
+
+	self.content = buf.Bytes()
 }
 
-// Sets the request contents.
-func (self *jsonContent) Set(value interface{}) {
-	data, err := json.Marshal(value)
-	if err == nil {
-		self.content = data
-		self.data = value
+// Encodes the contents into w.
+func (self *jsonContent) write(w io.Writer) error {
+	var err error
+
+	if self.callback != "" {
+		// The leading comment prevents the response from being interpreted as
+		// something else (e.g. a Flash file).
+		if _, err = fmt.Fprintf(w, "/**/%s(", self.callback); err != nil {
+			return err
+		}
+	}
+
+	if self.streaming() {
+		err = self.writeElements(w)
 	} else {
-		log.Printf("body.Json: %s\n", err)
+		err = self.encode(w, self.data, "")
 	}
+
+	if err != nil {
+		return err
+	}
+
+	if self.callback != "" {
+		_, err = io.WriteString(w, ");")
+	}
+
+	return err
 }
 
-// Returns the request contents that are going to be written.
-func (self *jsonContent) Get() []byte {
-	return self.content
+// Encodes a slice one element at a time.
+func (self *jsonContent) writeElements(w io.Writer) error {
+	pretty := self.options&JsonPretty != 0
+
+	value := reflect.ValueOf(self.data)
+
+	if value.Kind() == reflect.Slice && value.IsNil() {
+		_, err := io.WriteString(w, "null")
+		return err
+	}
+
+	open, separator, end := "[", ",", "]"
+
+	if pretty == true && value.Len() > 0 {
+		open, separator, end = "[\n  ", ",\n  ", "\n]"
+	}
+
+	if _, err := io.WriteString(w, open); err != nil {
+		return err
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+		}
+		if err := self.encode(w, value.Index(i).Interface(), "  "); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, end)
+
+	return err
+}
+
+// Encodes a single value without the trailing newline json.Encoder adds.
+func (self *jsonContent) encode(w io.Writer, value interface{}, prefix string) error {
+	buf := bytes.NewBuffer(nil)
+
+	encoder := json.NewEncoder(buf)
+
+	encoder.SetEscapeHTML(self.options&JsonRawHTML == 0)
+
+	if self.options&JsonPretty != 0 {
+		encoder.SetIndent(prefix, "  ")
+	}
+
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+
+	_, err := w.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+
+	return err
+}
+
+// Counts the bytes that go through a writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (self *countingWriter) Write(p []byte) (int, error) {
+	n, err := self.w.Write(p)
+	self.n += n
+	return n, err
 }
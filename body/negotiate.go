@@ -0,0 +1,266 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package body
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A media range from an Accept header.
+type mediaRange struct {
+	kind    string
+	subtype string
+	q       float64
+}
+
+// A representation the server is able to produce.
+type offer struct {
+	mime        string
+	contentType string
+	render      func(*negotiatedContent) ([]byte, error)
+}
+
+// Representations in order of server preference.
+var offers = []offer{
+	{"application/json", "application/json", (*negotiatedContent).renderJson},
+	{"application/xml", "application/xml; charset=utf8", (*negotiatedContent).renderXml},
+	{"text/xml", "text/xml; charset=utf8", (*negotiatedContent).renderXml},
+	{"text/html", "text/html; charset=utf8", (*negotiatedContent).renderHtml},
+	{"text/plain", "text/plain; charset=utf8", (*negotiatedContent).renderText},
+}
+
+type negotiatedContent struct {
+	status   int
+	header   http.Header
+	content  []byte
+	data     interface{}
+	template string
+	prepared bool
+	request  *http.Request
+}
+
+// Returns a Body that chooses between JSON, XML, HTML or plain text
+// according to the Accept header of the request. HTML is only offered when
+// the name of a template (see Templates) is given. If none of the
+// representations is acceptable the status will be 406.
+func Negotiate(data interface{}, template ...string) Body {
+	self := &negotiatedContent{}
+	self.status = 200
+	self.header = http.Header{}
+	self.header.Add("Vary", "Accept")
+	self.data = data
+	if len(template) > 0 {
+		self.template = template[0]
+	}
+	return self
+}
+
+// Chooses a representation for the given request.
+func (self *negotiatedContent) Prepare(req *http.Request) {
+	accept := ""
+
+	if req != nil {
+		accept = req.Header.Get("Accept")
+	}
+
+	self.request = req
+	self.prepared = true
+
+	for _, o := range acceptable(accept) {
+		content, err := o.render(self)
+		if err == nil {
+			self.content = content
+			self.status = 200
+			self.header.Set("Content-type", o.contentType)
+			return
+		}
+	}
+
+	self.content = []byte{}
+	self.status = 406
+}
+
+// Returns the headers to be sent along the request.
+func (self *negotiatedContent) Header() http.Header {
+	if self.prepared == false {
+		self.Prepare(nil)
+	}
+	return self.header
+}
+
+// Returns the request HTTP status.
+func (self *negotiatedContent) Status() int {
+	if self.prepared == false {
+		self.Prepare(nil)
+	}
+	return self.status
+}
+
+// Sets the request contents.
+func (self *negotiatedContent) Set(value interface{}) {
+	self.data = value
+	self.prepared = false
+}
+
+// Returns the request contents that are going to be written.
+func (self *negotiatedContent) Get() []byte {
+	if self.prepared == false {
+		self.Prepare(nil)
+	}
+	return self.content
+}
+
+func (self *negotiatedContent) renderJson() ([]byte, error) {
+	return json.Marshal(self.data)
+}
+
+func (self *negotiatedContent) renderXml() ([]byte, error) {
+	data, err := xml.Marshal(self.data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func (self *negotiatedContent) renderHtml() ([]byte, error) {
+	if self.template == "" {
+		return nil, fmt.Errorf("No template was given.")
+	}
+	return renderTemplate(self.template, self.data, self.request)
+}
+
+func (self *negotiatedContent) renderText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%v", self.data)), nil
+}
+
+// Parses an Accept header, a missing header accepts anything.
+func parseAccept(header string) []mediaRange {
+	ranges := []mediaRange{}
+
+	if strings.TrimSpace(header) == "" {
+		header = "*/*"
+	}
+
+	for _, item := range strings.Split(header, ",") {
+		params := strings.Split(item, ";")
+
+		mime := strings.ToLower(strings.TrimSpace(params[0]))
+
+		chunks := strings.SplitN(mime, "/", 2)
+
+		if len(chunks) != 2 || chunks[0] == "" || chunks[1] == "" {
+			continue
+		}
+
+		r := mediaRange{kind: chunks[0], subtype: chunks[1], q: 1}
+
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(strings.TrimSpace(kv[0])) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err != nil || q < 0 {
+					q = 0
+				}
+				if q > 1 {
+					q = 1
+				}
+				r.q = q
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// Returns the quality the client gives to a MIME type, the most specific
+// matching range wins.
+func quality(ranges []mediaRange, mime string) float64 {
+	chunks := strings.SplitN(mime, "/", 2)
+
+	q := 0.0
+	best := -1
+
+	for _, r := range ranges {
+		specificity := -1
+
+		switch {
+		case r.kind == chunks[0] && r.subtype == chunks[1]:
+			specificity = 2
+		case r.kind == chunks[0] && r.subtype == "*":
+			specificity = 1
+		case r.kind == "*" && r.subtype == "*":
+			specificity = 0
+		}
+
+		if specificity > best {
+			best = specificity
+			q = r.q
+		}
+	}
+
+	return q
+}
+
+// Returns the acceptable offers, sorted by client preference and then by
+// server preference.
+func acceptable(header string) []offer {
+	ranges := parseAccept(header)
+
+	type candidate struct {
+		offer
+		q float64
+	}
+
+	candidates := []candidate{}
+
+	for _, o := range offers {
+		q := quality(ranges, o.mime)
+		if q > 0 {
+			candidates = append(candidates, candidate{o, q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	result := make([]offer, len(candidates))
+
+	for i := range candidates {
+		result[i] = candidates[i].offer
+	}
+
+	return result
+}
@@ -0,0 +1,152 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package body
+
+import (
+	"encoding/json"
+	"github.com/astrata/tango/logger"
+	"net/http"
+)
+
+// Problem details for HTTP APIs, as described in RFC 7807.
+type ProblemDetails struct {
+	// A URI reference that identifies the problem type.
+	Type string
+	// A short, human-readable summary of the problem type.
+	Title string
+	// The HTTP status code.
+	Status int
+	// A human-readable explanation specific to this occurrence of the problem.
+	Detail string
+	// A URI reference that identifies the specific occurrence of the problem.
+	Instance string
+	// Extension members, they're written along the standard members.
+	Extensions map[string]interface{}
+}
+
+// Encodes the problem as a JSON object with its extension members at the top
+// level.
+func (self ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+
+	for k, v := range self.Extensions {
+		members[k] = v
+	}
+
+	members["type"] = self.Type
+	members["title"] = self.Title
+	members["status"] = self.Status
+
+	if self.Detail != "" {
+		members["detail"] = self.Detail
+	}
+
+	if self.Instance != "" {
+		members["instance"] = self.Instance
+	}
+
+	return json.Marshal(members)
+}
+
+type problemContent struct {
+	status  int
+	header  http.Header
+	content []byte
+}
+
+// Returns a Body for an application/problem+json response.
+func Problem() Body {
+	self := &problemContent{}
+	self.header = http.Header{}
+	self.header.Add("Content-type", "application/problem+json")
+	self.Set(ProblemDetails{})
+	return self
+}
+
+// Returns the headers to be sent along the request.
+func (self *problemContent) Header() http.Header {
+	return self.header
+}
+
+// Returns the request HTTP status.
+func (self *problemContent) Status() int {
+	return self.status
+}
+
+// Sets the request contents. It accepts a ProblemDetails struct, an HTTP
+// status code or a map of per-field error messages (like the ones returned
+// by validation.Rules.Validate) that is sent as a 422 with an "errors"
+// member.
+func (self *problemContent) Set(value interface{}) {
+	var details ProblemDetails
+
+	switch v := value.(type) {
+	case ProblemDetails:
+		details = v
+	case *ProblemDetails:
+		details = *v
+	case int:
+		details = ProblemDetails{Status: v}
+	case map[string][]string:
+		details = ProblemDetails{
+			Status:     422,
+			Title:      "Validation failed",
+			Detail:     "One or more fields have invalid values.",
+			Extensions: map[string]interface{}{"errors": v},
+		}
+	default:
+		details = ProblemDetails{Status: 500}
+	}
+
+	if details.Status == 0 {
+		details.Status = 500
+	}
+
+	if details.Type == "" {
+		details.Type = "about:blank"
+	}
+
+	if details.Title == "" {
+		details.Title = http.StatusText(details.Status)
+	}
+
+	data, err := json.Marshal(details)
+
+	if err != nil {
+		logger.Error("body.Problem: could not encode problem.", "error", err)
+		details = ProblemDetails{Type: "about:blank", Status: 500, Title: http.StatusText(500)}
+		data, _ = json.Marshal(details)
+	}
+
+	self.status = details.Status
+	self.content = data
+}
+
+// Returns the request contents that are going to be written.
+func (self *problemContent) Get() []byte {
+	return self.content
+}
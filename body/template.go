@@ -0,0 +1,180 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package body
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+)
+
+// Templates that bodies can use to render HTML. Apps usually load them when
+// starting up, e.g.:
+//
+//	body.Templates = template.Must(template.ParseGlob("views/*.html"))
+var Templates *template.Template
+
+// Functions that depend on the request being rendered.
+var (
+	requestFuncs   = map[string]func(*http.Request) interface{}{}
+	requestFuncsMu sync.RWMutex
+)
+
+// Registers a function that templates can call by name and whose result
+// depends on the request being served (e.g. the flash messages of the
+// current user). Templates must be parsed with TemplateFuncs() so the names
+// are known.
+func TemplateFunc(name string, fn func(*http.Request) interface{}) {
+	requestFuncsMu.Lock()
+	requestFuncs[name] = fn
+	requestFuncsMu.Unlock()
+
+	// Clones made so far don't know the new function.
+	templateCache.Lock()
+	templateCache.source = nil
+	templateCache.Unlock()
+}
+
+// Returns placeholders for the functions registered with TemplateFunc(),
+// e.g.:
+//
+//	body.Templates = template.Must(template.New("").Funcs(body.TemplateFuncs()).ParseGlob("views/*.html"))
+func TemplateFuncs() template.FuncMap {
+	return bindFuncs(nil)
+}
+
+// A clone of Templates whose request functions read the request being
+// rendered with it.
+type boundTemplates struct {
+	tpl *template.Template
+	req *http.Request
+}
+
+// Binds the registered functions to the request of a clone.
+func bindFuncs(bound *boundTemplates) template.FuncMap {
+	requestFuncsMu.RLock()
+	defer requestFuncsMu.RUnlock()
+
+	funcs := template.FuncMap{}
+
+	for name, fn := range requestFuncs {
+		fn := fn
+		funcs[name] = func() interface{} {
+			if bound == nil || bound.req == nil {
+				return nil
+			}
+			return fn(bound.req)
+		}
+	}
+
+	return funcs
+}
+
+// Clones of Templates, rebuilt when Templates is replaced. Templates itself
+// is never executed, so it can still be cloned.
+var templateCache struct {
+	sync.Mutex
+	source *template.Template
+	// Bound clones that are not rendering anything.
+	pool *sync.Pool
+}
+
+// Returns the pool of bound clones of Templates, it's nil if Templates can't
+// be cloned because the app executed it directly.
+func templatePool() (*sync.Pool, error) {
+	templateCache.Lock()
+	defer templateCache.Unlock()
+
+	if Templates == nil {
+		return nil, fmt.Errorf("No templates were loaded.")
+	}
+
+	if templateCache.source != Templates {
+		templateCache.source = Templates
+		templateCache.pool = nil
+
+		// The first clone is never executed, so it can be cloned again.
+		if base, err := Templates.Clone(); err == nil {
+			templateCache.pool = &sync.Pool{
+				New: func() interface{} {
+					clone, err := base.Clone()
+					if err != nil {
+						return nil
+					}
+					bound := &boundTemplates{}
+					bound.tpl = clone.Funcs(bindFuncs(bound))
+					return bound
+				},
+			}
+		}
+	}
+
+	return templateCache.pool, nil
+}
+
+// Executes the named template with the given data. Clones are reused across
+// renders, each one serves a single request at a time so functions can be
+// bound to it.
+func renderTemplate(name string, data interface{}, req *http.Request) ([]byte, error) {
+	pool, err := templatePool()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Request functions are not available if the templates can't be cloned.
+	tpl := Templates
+
+	if pool != nil {
+		if bound, ok := pool.Get().(*boundTemplates); ok {
+			bound.req = req
+			defer func() {
+				bound.req = nil
+				pool.Put(bound)
+			}()
+			tpl = bound.tpl
+		}
+	}
+
+	tpl = tpl.Lookup(name)
+
+	if tpl == nil {
+		return nil, fmt.Errorf("Template %s does not exist.", name)
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	err = tpl.Execute(buf, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
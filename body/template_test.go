@@ -0,0 +1,81 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package body
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	TemplateFunc("test_path", func(req *http.Request) interface{} {
+		return req.URL.Path
+	})
+
+	Templates = template.Must(template.New("").Funcs(TemplateFuncs()).Parse(`{{define "page"}}{{test_path}}:{{.}}{{end}}`))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			path := fmt.Sprintf("/%d", i)
+
+			out, err := renderTemplate("page", i, httptest.NewRequest("GET", path, nil))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if want := fmt.Sprintf("%s:%d", path, i); string(out) != want {
+				t.Errorf("Got %q, expecting %q.", out, want)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Without a request the functions return nothing.
+	if out, err := renderTemplate("page", "x", nil); err != nil || string(out) != ":x" {
+		t.Fatalf("Got %q (%v), expecting %q.", out, err, ":x")
+	}
+
+	if _, err := renderTemplate("missing", nil, nil); err == nil {
+		t.Fatalf("Expecting an error for a missing template.")
+	}
+
+	// Templates can still be cloned, so replacing them works too.
+	if _, err := Templates.Clone(); err != nil {
+		t.Fatalf("Templates were executed directly: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package clf
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Returned when writing into a closed AsyncWriter or RotatingFile.
+var ErrClosed = errors.New("Writer is closed.")
+
+// What an AsyncWriter does when its queue is full.
+type Overflow int
+
+const (
+	// Waits until there's room in the queue.
+	Block Overflow = iota
+	// Discards the line.
+	Drop
+)
+
+// Writes lines in the background, so requests don't wait for the disk.
+// Lines are queued and written in batches whenever the queue runs empty.
+type AsyncWriter struct {
+	out    io.Writer
+	queue  chan []byte
+	policy Overflow
+
+	dropped uint64
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// Allocates a new &AsyncWriter{} that queues up to size lines for out.
+func NewAsyncWriter(out io.Writer, size int, policy Overflow) *AsyncWriter {
+	self := &AsyncWriter{}
+	self.out = out
+	self.queue = make(chan []byte, size)
+	self.policy = policy
+	self.done = make(chan struct{})
+
+	go self.run()
+
+	return self
+}
+
+func (self *AsyncWriter) run() {
+	buf := bufio.NewWriterSize(self.out, 64*1024)
+
+	for line := range self.queue {
+		buf.Write(line)
+		if len(self.queue) == 0 {
+			buf.Flush()
+		}
+	}
+
+	buf.Flush()
+
+	close(self.done)
+}
+
+// Queues a copy of p.
+func (self *AsyncWriter) Write(p []byte) (int, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	if self.closed == true {
+		return 0, ErrClosed
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	if self.policy == Drop {
+		select {
+		case self.queue <- line:
+		default:
+			atomic.AddUint64(&self.dropped, 1)
+		}
+	} else {
+		self.queue <- line
+	}
+
+	return len(p), nil
+}
+
+// Returns the number of lines discarded because the queue was full.
+func (self *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&self.dropped)
+}
+
+// Writes the lines left in the queue and closes the underlying writer if
+// it's an io.Closer other than standard output or standard error.
+func (self *AsyncWriter) Close() error {
+	self.mu.Lock()
+
+	if self.closed == true {
+		self.mu.Unlock()
+		return nil
+	}
+
+	self.closed = true
+	close(self.queue)
+
+	self.mu.Unlock()
+
+	<-self.done
+
+	if self.out == os.Stdout || self.out == os.Stderr {
+		return nil
+	}
+
+	if closer, ok := self.out.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -25,40 +25,130 @@
 */
 
 /*
-  This package implements the Common Log Format for any *http.Requests and
-  prints the result to os.Stdout.
+  This package writes access logs for *http.Requests in the Common Log
+  Format [1], the Combined Log Format, JSON or a custom format.
 
   [1]: http://en.wikipedia.org/wiki/Common_Log_Format
 */
 package clf
 
 import (
-	"fmt"
+	"io"
 	"net/http"
-	"strings"
+	"os"
+	"sync"
 	"time"
 )
 
-func chunk(value string) string {
-	if value == "" {
-		return "-"
+// A request to be logged.
+type Entry struct {
+	Request *http.Request
+	// Address of the client, Request.RemoteAddr if empty.
+	RemoteAddr string
+	// Authenticated user.
+	User   string
+	Status int
+	Size   int
+	// When the request arrived, the time of logging if zero.
+	Time time.Time
+	// Time spent serving the request.
+	Duration  time.Duration
+	RequestID string
+}
+
+// Writes access log lines.
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+	parsed []directive
+}
+
+// Allocates a new &Logger{} that writes lines with the given format (see
+// Format) into out.
+func New(out io.Writer, format string) *Logger {
+	self := &Logger{out: out}
+	self.SetFormat(format)
+	return self
+}
+
+// Default logger, writes CLF lines to stdout.
+var std = New(os.Stdout, Default)
+
+// Changes the format of the lines.
+func (self *Logger) SetFormat(format string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.format = format
+	self.parsed = parseFormat(format)
+}
+
+// Changes the destination of the lines.
+func (self *Logger) SetOutput(out io.Writer) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.out = out
+}
+
+// Closes the destination if it's an io.Closer, an AsyncWriter writes its
+// queued lines first. Standard output and standard error are left open.
+func (self *Logger) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.out == os.Stdout || self.out == os.Stderr {
+		return nil
+	}
+
+	if closer, ok := self.out.(io.Closer); ok {
+		return closer.Close()
 	}
-	return value
+
+	return nil
 }
 
-// Prints a Common Log Format of a request to stdout.
+// Writes an entry.
+func (self *Logger) Log(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	if entry.RemoteAddr == "" {
+		entry.RemoteAddr = entry.Request.RemoteAddr
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	var line []byte
+
+	if self.format == JSON {
+		line = formatJSON(entry)
+	} else {
+		line = formatLine(self.parsed, entry)
+	}
+
+	self.out.Write(append(line, '\n'))
+}
+
+// Changes the format of the default logger.
+func SetFormat(format string) {
+	std.SetFormat(format)
+}
+
+// Changes the destination of the default logger.
+func SetOutput(out io.Writer) {
+	std.SetOutput(out)
+}
+
+// Prints a Common Log Format of a request to the default logger.
 func Print(req *http.Request, status int, size int) {
-	fmt.Println(
-		strings.Join([]string{
-			chunk(req.RemoteAddr),
-			chunk(""), // TODO: http://tools.ietf.org/html/rfc1413 IDENT
-			chunk(""), // TODO: User id
-			chunk("[" + time.Now().Format("02/Jan/2006:15:04:05 -0700") + "]"),
-			chunk("\"" + fmt.Sprintf("%s %s %s", req.Method, req.RequestURI, req.Proto) + "\""),
-			chunk(fmt.Sprintf("%d", status)),
-			chunk(fmt.Sprintf("%d", size)),
-		},
-			" ",
-		),
-	)
+	Log(Entry{Request: req, Status: status, Size: size})
+}
+
+// Writes an entry with the default logger.
+func Log(entry Entry) {
+	std.Log(entry)
 }
@@ -0,0 +1,238 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package clf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats, custom formats use the same directives as Apache's mod_log_config:
+//
+//	%h  client address         %u  user             %t  time
+//	%r  request line           %s  status (%>s)     %b  size, "-" if 0
+//	%B  size                   %m  method           %U  path
+//	%q  query string           %H  protocol         %v  host
+//	%D  duration (µs)          %T  duration (s)     %L  request ID
+//	%l  ident, always "-"      %%  literal "%"
+//	%{Name}i  request header
+const (
+	// Common Log Format.
+	Common = `%h %l %u %t "%r" %>s %b`
+	// Combined Log Format, CLF plus referer and user agent.
+	Combined = Common + ` "%{Referer}i" "%{User-Agent}i"`
+	// CLF plus the request ID.
+	CommonID = Common + ` %L`
+	// Strict CLF, so standard tools can parse the lines.
+	Default = Common
+	// One JSON object per line.
+	JSON = "json"
+)
+
+// A piece of a parsed format: either literal text or a directive.
+type directive struct {
+	text string
+	verb byte
+	arg  string
+}
+
+// Splits a format into directives.
+func parseFormat(format string) []directive {
+	parsed := []directive{}
+	text := []byte{}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			text = append(text, format[i])
+			continue
+		}
+
+		i++
+
+		if format[i] == '%' {
+			text = append(text, '%')
+			continue
+		}
+
+		d := directive{}
+
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				text = append(text, format[i-1:]...)
+				break
+			}
+			d.arg = format[i+1 : i+end]
+			i += end + 1
+		}
+
+		// Apache's %>s (final status) is the same as %s here.
+		if i < len(format) && format[i] == '>' {
+			i++
+		}
+
+		if i >= len(format) {
+			break
+		}
+
+		d.verb = format[i]
+
+		if len(text) > 0 {
+			parsed = append(parsed, directive{text: string(text)})
+			text = []byte{}
+		}
+
+		parsed = append(parsed, d)
+	}
+
+	if len(text) > 0 {
+		parsed = append(parsed, directive{text: string(text)})
+	}
+
+	return parsed
+}
+
+func chunk(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// Escapes quotes and control characters of values taken from requests.
+func escape(value string) string {
+	quoted := strconv.Quote(value)
+	return quoted[1 : len(quoted)-1]
+}
+
+// Writes an entry with a parsed format.
+func formatLine(parsed []directive, entry Entry) []byte {
+	req := entry.Request
+
+	line := make([]byte, 0, 128)
+
+	for _, d := range parsed {
+		if d.verb == 0 {
+			line = append(line, d.text...)
+			continue
+		}
+
+		var value string
+
+		switch d.verb {
+		case 'h':
+			value = chunk(entry.RemoteAddr)
+		case 'l':
+			value = "-"
+		case 'u':
+			value = chunk(escape(entry.User))
+		case 't':
+			value = "[" + entry.Time.Format("02/Jan/2006:15:04:05 -0700") + "]"
+		case 'r':
+			value = escape(fmt.Sprintf("%s %s %s", req.Method, req.RequestURI, req.Proto))
+		case 's':
+			value = strconv.Itoa(entry.Status)
+		case 'b':
+			if entry.Size == 0 {
+				value = "-"
+			} else {
+				value = strconv.Itoa(entry.Size)
+			}
+		case 'B':
+			value = strconv.Itoa(entry.Size)
+		case 'm':
+			value = escape(req.Method)
+		case 'U':
+			value = escape(req.URL.Path)
+		case 'q':
+			if req.URL.RawQuery != "" {
+				value = "?" + escape(req.URL.RawQuery)
+			}
+		case 'H':
+			value = escape(req.Proto)
+		case 'v':
+			value = chunk(escape(req.Host))
+		case 'D':
+			value = strconv.FormatInt(int64(entry.Duration/time.Microsecond), 10)
+		case 'T':
+			value = strconv.FormatInt(int64(entry.Duration/time.Second), 10)
+		case 'L':
+			value = chunk(entry.RequestID)
+		case 'i':
+			value = chunk(escape(req.Header.Get(d.arg)))
+		default:
+			value = "%" + string(d.verb)
+		}
+
+		line = append(line, value...)
+	}
+
+	return line
+}
+
+// A line of the JSON format.
+type jsonLine struct {
+	Time      string  `json:"time"`
+	Remote    string  `json:"remote"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	Host      string  `json:"host"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Size      int     `json:"size"`
+	Duration  float64 `json:"duration_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+// Writes an entry as a JSON object.
+func formatJSON(entry Entry) []byte {
+	req := entry.Request
+
+	line, _ := json.Marshal(jsonLine{
+		Time:      entry.Time.Format(time.RFC3339Nano),
+		Remote:    entry.RemoteAddr,
+		User:      entry.User,
+		Method:    req.Method,
+		Host:      req.Host,
+		URI:       req.RequestURI,
+		Proto:     req.Proto,
+		Status:    entry.Status,
+		Size:      entry.Size,
+		Duration:  float64(entry.Duration) / float64(time.Millisecond),
+		Referer:   req.Referer(),
+		UserAgent: req.UserAgent(),
+		RequestID: entry.RequestID,
+	})
+
+	return line
+}
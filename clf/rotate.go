@@ -0,0 +1,249 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package clf
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/astrata/tango/logger"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Anything that can reopen its output, see ReopenOnSignal().
+type Reopener interface {
+	Reopen() error
+}
+
+// A log file that is rotated when it grows past MaxSize bytes or every
+// Interval, rotated files get a timestamp suffix (access.log.20130102-150405)
+// and are gzipped if Compress is set. A zero MaxSize or Interval disables
+// that kind of rotation.
+type RotatingFile struct {
+	Path     string
+	MaxSize  int64
+	Interval time.Duration
+	Compress bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	next   time.Time
+	closed bool
+
+	// Files being gzipped.
+	compressing sync.WaitGroup
+}
+
+// Opens a rotating log file.
+func OpenRotatingFile(path string, maxSize int64, interval time.Duration, compress bool) (*RotatingFile, error) {
+	self := &RotatingFile{Path: path, MaxSize: maxSize, Interval: interval, Compress: compress}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if err := self.open(); err != nil {
+		return nil, err
+	}
+
+	return self, nil
+}
+
+func (self *RotatingFile) open() error {
+	file, err := OpenFile(self.Path)
+
+	if err != nil {
+		return err
+	}
+
+	self.file = file.(*os.File)
+	self.size = 0
+
+	if stat, err := self.file.Stat(); err == nil {
+		self.size = stat.Size()
+	}
+
+	if self.Interval > 0 {
+		self.next = time.Now().Truncate(self.Interval).Add(self.Interval)
+	}
+
+	return nil
+}
+
+// Writes into the file, rotating it first if needed.
+func (self *RotatingFile) Write(p []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed == true {
+		return 0, ErrClosed
+	}
+
+	if self.file == nil {
+		if err := self.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	full := self.MaxSize > 0 && self.size > 0 && self.size+int64(len(p)) > self.MaxSize
+	expired := self.Interval > 0 && time.Now().Before(self.next) == false
+
+	if full || expired {
+		if err := self.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := self.file.Write(p)
+
+	self.size += int64(n)
+
+	return n, err
+}
+
+// Rotates the file now.
+func (self *RotatingFile) Rotate() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed == true {
+		return ErrClosed
+	}
+
+	return self.rotate()
+}
+
+func (self *RotatingFile) rotate() error {
+	if self.file != nil {
+		self.file.Close()
+		self.file = nil
+	}
+
+	name := self.Path + "." + time.Now().Format("20060102-150405")
+
+	// Not overwriting files rotated within the same second.
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			if _, err := os.Stat(name + ".gz"); os.IsNotExist(err) {
+				break
+			}
+		}
+		name = fmt.Sprintf("%s.%s.%d", self.Path, time.Now().Format("20060102-150405"), i)
+	}
+
+	if err := os.Rename(self.Path, name); err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+
+	if self.Compress == true {
+		self.compressing.Add(1)
+		go func() {
+			defer self.compressing.Done()
+			compress(name)
+		}()
+	}
+
+	return self.open()
+}
+
+// Closes and opens the file again, for when it was moved away by an external
+// tool like logrotate.
+func (self *RotatingFile) Reopen() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed == true {
+		return ErrClosed
+	}
+
+	if self.file != nil {
+		self.file.Close()
+		self.file = nil
+	}
+
+	return self.open()
+}
+
+// Closes the file and waits for rotated files to be gzipped. Writes fail
+// afterwards.
+func (self *RotatingFile) Close() error {
+	self.mu.Lock()
+
+	self.closed = true
+
+	var err error
+
+	if self.file != nil {
+		err = self.file.Close()
+		self.file = nil
+	}
+
+	self.mu.Unlock()
+
+	self.compressing.Wait()
+
+	return err
+}
+
+// Replaces a file with a gzipped copy.
+func compress(name string) {
+	err := func() error {
+		src, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return err
+		}
+
+		zw := gzip.NewWriter(dst)
+
+		if _, err = io.Copy(zw, src); err == nil {
+			err = zw.Close()
+		}
+
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			os.Remove(name + ".gz")
+			return err
+		}
+
+		return os.Remove(name)
+	}()
+
+	if err != nil {
+		logger.Error("Could not compress rotated log.", "file", name, "error", err)
+	}
+}
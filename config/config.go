@@ -27,8 +27,10 @@
 package config
 
 import (
+	"fmt"
+	"github.com/astrata/tango/logger"
+	"github.com/gosexy/sugar"
 	"github.com/gosexy/yaml"
-	"log"
 	"os"
 )
 
@@ -46,7 +48,7 @@ func Load(file string) {
 	settings = yaml.New()
 	err := settings.Read(file)
 	if err != nil {
-		log.Printf("Could not read settings file. %s: %s\n", file, err.Error())
+		logger.Warn("Could not read settings file.", "file", file, "error", err)
 	}
 }
 
@@ -54,3 +56,30 @@ func Load(file string) {
 func Get(name string) interface{} {
 	return settings.Get(name)
 }
+
+// Returns a configuration setting as a list of strings. A single value is
+// returned as a list with one element.
+func Strings(name string) []string {
+	var list []interface{}
+
+	switch value := Get(name).(type) {
+	case nil:
+		return []string{}
+	case sugar.List:
+		list = value
+	case []interface{}:
+		list = value
+	case []string:
+		return value
+	default:
+		return []string{fmt.Sprintf("%v", value)}
+	}
+
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		result = append(result, fmt.Sprintf("%v", item))
+	}
+
+	return result
+}
@@ -27,11 +27,17 @@
 package tango
 
 import (
+	gocontext "context"
 	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/jwt"
+	"github.com/astrata/tango/logger"
+	"github.com/astrata/tango/session"
 	"github.com/gosexy/to"
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Each request has its own Context struct that contains info about the request type and provides
@@ -60,12 +66,43 @@ type Context struct {
 	Files Files
 
 	cookieMap map[string]*http.Cookie
+
+	session *session.Session
+
+	// Authenticated user.
+	user string
+
+	// Claims of a verified JWT.
+	claims jwt.Claims
+
+	// CSP nonce.
+	nonce string
+
+	// Resolved client address.
+	clientIP string
+
+	requestID string
+
+	logger logger.Logger
+
+	// When the request arrived.
+	started time.Time
+
+	// Route and model method that served the request, for metrics.
+	route string
+
+	// The writer behind Writer.
+	response *responseWriter
+
+	executed bool
 }
 
 func newContext(server *Server, writer http.ResponseWriter, request *http.Request) *Context {
 
 	context := &Context{}
 
+	context.started = time.Now()
+
 	switch request.Method {
 	case "GET":
 		context.GET = true
@@ -79,14 +116,25 @@ func newContext(server *Server, writer http.ResponseWriter, request *http.Reques
 
 	context.cookieMap = make(map[string]*http.Cookie)
 
-	context.Server = server
-	context.Request = request
-	context.Writer = writer
-
 	maxSize := to.Int64(config.Get("server/request_max_size"))
 
 	request.ParseMultipartForm(maxSize)
 
+	context.requestID = newRequestID(request)
+
+	writer.Header().Set(RequestIDHeader, context.requestID)
+
+	// Keeping a reference to the context within the request, see contextOf().
+	ctx := gocontext.WithValue(request.Context(), contextKey{}, context)
+	ctx = gocontext.WithValue(ctx, requestIDKey{}, context.requestID)
+
+	request = request.WithContext(ctx)
+
+	context.Server = server
+	context.Request = request
+	context.response = &responseWriter{ResponseWriter: writer}
+	context.Writer = context.response
+
 	context.Params = context.getParams()
 	context.Cookies = context.getCookies()
 	context.Files = context.getFiles()
@@ -96,6 +144,19 @@ func newContext(server *Server, writer http.ResponseWriter, request *http.Reques
 	return context
 }
 
+// Key for storing a *Context within a request.
+type contextKey struct{}
+
+// Returns the *Context of a request, for code that only gets to see the
+// *http.Request (like template functions).
+func contextOf(request *http.Request) *Context {
+	if request == nil {
+		return nil
+	}
+	context, _ := request.Context().Value(contextKey{}).(*Context)
+	return context
+}
+
 func cast(t reflect.Type, value string) reflect.Value {
 	result := reflect.Zero(t)
 	// Is there a cleaner way of doing this?
@@ -118,6 +179,8 @@ func (context *Context) Cookie(name string) *http.Cookie {
 	cookie := &http.Cookie{}
 	cookie.Name = name
 
+	cookieDefaults(cookie)
+
 	context.cookieMap[name] = cookie
 
 	return cookie
@@ -125,11 +188,17 @@ func (context *Context) Cookie(name string) *http.Cookie {
 
 // Sends a HTTP error code.
 func (context *Context) HttpError(code int) {
-	http.Error(context.Writer, http.StatusText(code), code)
+	// Headers can't be changed after this.
+	context.afterExecute()
+	context.errorPage(code)
 }
 
-// Sends a 301 Location header with the given value.
+// Sends a 301 Location header with the given value, absolute paths are
+// completed with the scheme and host the client used.
 func (context *Context) Redirect(value string) {
+	if strings.HasPrefix(value, "/") && strings.HasPrefix(value, "//") == false {
+		value = context.Scheme() + "://" + context.Host() + value
+	}
 	context.Writer.Header().Set("Location", value)
 	context.HttpError(301)
 }
@@ -140,6 +209,14 @@ func (context *Context) SetHeader(name string, value string) {
 }
 
 func (context *Context) afterExecute() {
+	if context.executed == true {
+		return
+	}
+
+	context.executed = true
+
+	context.saveSession()
+
 	for key, _ := range context.cookieMap {
 		http.SetCookie(context.Writer, context.cookieMap[key])
 	}
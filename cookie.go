@@ -0,0 +1,283 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/gosexy/to"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Returned by Context.SecureCookie() when the cookie is missing, was
+// tampered with or has expired.
+var ErrInvalidCookie = errors.New("Invalid or missing cookie.")
+
+// Keys derived from a security/keys entry.
+type cookieKey struct {
+	sign    []byte
+	encrypt cipher.AEAD
+}
+
+var (
+	cookieKeys     []cookieKey
+	cookieKeysOnce sync.Once
+)
+
+// Derives a purpose-specific key from a secret.
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Returns the secrets listed in security/keys, newest first. If none are
+// configured a random one is generated, values signed with it will not
+// survive a restart.
+func secretKeys() [][]byte {
+	secrets := [][]byte{}
+
+	for _, key := range config.Strings("security/keys") {
+		if key != "" {
+			secrets = append(secrets, []byte(key))
+		}
+	}
+
+	if len(secrets) == 0 {
+		// Older setting.
+		if key := to.String(config.Get("session/secret")); key != "" {
+			secrets = append(secrets, []byte(key))
+		}
+	}
+
+	if len(secrets) == 0 {
+		logger.Warn("security/keys is not set, signed values will not survive a restart.")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err.Error())
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets
+}
+
+func loadCookieKeys() {
+	for _, secret := range secretKeys() {
+		block, err := aes.NewCipher(deriveKey(secret, "tango cookie encryption"))
+		if err != nil {
+			panic(err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err.Error())
+		}
+		cookieKeys = append(cookieKeys, cookieKey{deriveKey(secret, "tango cookie signing"), aead})
+	}
+}
+
+// Signs a message with the given key.
+func (key cookieKey) mac(message string) []byte {
+	mac := hmac.New(sha256.New, key.sign)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// Encodes a cookie value as timestamp.flag.data.signature, the cookie name
+// is part of the signed message so values can't be moved between cookies.
+func encodeCookie(name string, value string, encrypt bool) (string, error) {
+	cookieKeysOnce.Do(loadCookieKeys)
+
+	key := cookieKeys[0]
+
+	data := []byte(value)
+	flag := "s"
+
+	if encrypt == true {
+		nonce := make([]byte, key.encrypt.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		data = key.encrypt.Seal(nonce, nonce, data, []byte(name))
+		flag = "e"
+	}
+
+	payload := strconv.FormatInt(time.Now().Unix(), 10) + "." + flag + "." + base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(key.mac(name+"|"+payload)), nil
+}
+
+// Verifies and decodes a cookie value, every configured key is tried so
+// values signed before a key rotation are still accepted.
+func decodeCookie(name string, value string, maxAge time.Duration) (string, error) {
+	cookieKeysOnce.Do(loadCookieKeys)
+
+	chunks := strings.Split(value, ".")
+
+	if len(chunks) != 4 {
+		return "", ErrInvalidCookie
+	}
+
+	payload := strings.Join(chunks[:3], ".")
+
+	signature, err := base64.RawURLEncoding.DecodeString(chunks[3])
+
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range cookieKeys {
+		if hmac.Equal(key.mac(name+"|"+payload), signature) == false {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(chunks[0], 10, 64)
+
+		if err != nil {
+			return "", ErrInvalidCookie
+		}
+
+		if maxAge > 0 && time.Since(time.Unix(timestamp, 0)) > maxAge {
+			return "", ErrInvalidCookie
+		}
+
+		data, err := base64.RawURLEncoding.DecodeString(chunks[2])
+
+		if err != nil {
+			return "", ErrInvalidCookie
+		}
+
+		switch chunks[1] {
+		case "s":
+			return string(data), nil
+		case "e":
+			size := key.encrypt.NonceSize()
+			if len(data) < size {
+				return "", ErrInvalidCookie
+			}
+			plain, err := key.encrypt.Open(nil, data[:size], data[size:], []byte(name))
+			if err != nil {
+				return "", ErrInvalidCookie
+			}
+			return string(plain), nil
+		}
+
+		return "", ErrInvalidCookie
+	}
+
+	return "", ErrInvalidCookie
+}
+
+// Applies the cookie/* defaults from settings.yaml.
+func cookieDefaults(cookie *http.Cookie) {
+	if path := to.String(config.Get("cookie/path")); path != "" {
+		cookie.Path = path
+	}
+
+	if domain := to.String(config.Get("cookie/domain")); domain != "" {
+		cookie.Domain = domain
+	}
+
+	if config.Get("cookie/http_only") != nil {
+		cookie.HttpOnly = to.Bool(config.Get("cookie/http_only"))
+	}
+
+	if config.Get("cookie/secure") != nil {
+		cookie.Secure = to.Bool(config.Get("cookie/secure"))
+	}
+
+	switch strings.ToLower(to.String(config.Get("cookie/same_site"))) {
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+}
+
+// Creates a cookie with a signed value that can't be modified by the client.
+// The value is also encrypted if cookie/encrypt is true. Secure cookies are
+// HttpOnly and SameSite=Lax unless settings.yaml says otherwise.
+func (context *Context) SetSecureCookie(name string, value string) (*http.Cookie, error) {
+	return context.setSecureCookie(name, value, to.Bool(config.Get("cookie/encrypt")))
+}
+
+// Like SetSecureCookie() but the value is always encrypted.
+func (context *Context) SetEncryptedCookie(name string, value string) (*http.Cookie, error) {
+	return context.setSecureCookie(name, value, true)
+}
+
+func (context *Context) setSecureCookie(name string, value string, encrypt bool) (*http.Cookie, error) {
+	encoded, err := encodeCookie(name, value, encrypt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cookie := &http.Cookie{}
+	cookie.Name = name
+	cookie.Path = "/"
+	cookie.HttpOnly = true
+	// Behind a trusted proxy that terminates TLS the request itself is plain.
+	cookie.Secure = context.Scheme() == "https"
+	cookie.SameSite = http.SameSiteLaxMode
+
+	cookieDefaults(cookie)
+
+	cookie.Value = encoded
+
+	context.cookieMap[name] = cookie
+
+	return cookie, nil
+}
+
+// Returns the value of a cookie created with SetSecureCookie() or
+// SetEncryptedCookie(). Cookies older than cookie/max_age seconds are
+// rejected.
+func (context *Context) SecureCookie(name string) (string, error) {
+	value, ok := context.Cookies[name]
+
+	if ok == false {
+		return "", ErrInvalidCookie
+	}
+
+	maxAge := time.Duration(to.Int64(config.Get("cookie/max_age"))) * time.Second
+
+	return decodeCookie(name, value.(string), maxAge)
+}
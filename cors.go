@@ -0,0 +1,335 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"fmt"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/gosexy/to"
+	"net/http"
+	"strings"
+)
+
+// Default methods for cross-origin requests.
+var defaultCORSMethods = []string{"GET", "HEAD", "POST"}
+
+// Which cross-origin requests are allowed (see CORS()).
+type CORSPolicy struct {
+	// Origins that may send requests, like "https://example.com". A "*"
+	// within an origin matches any part of it (e.g. "https://*.example.com")
+	// and "*" alone matches every origin.
+	Origins []string
+	// Methods that may be used, GET, HEAD and POST if empty.
+	Methods []string
+	// Request headers that may be sent, any header the client asks for if
+	// empty.
+	Headers []string
+	// Response headers the client may read.
+	Expose []string
+	// Allows cookies and Authorization headers, the origins must be listed
+	// (a "*" origin is rejected).
+	Credentials bool
+	// Seconds a preflight may be cached by the client.
+	MaxAge int
+}
+
+// Returns true if the origin matches a pattern.
+func matchOrigin(pattern string, origin string) bool {
+	pattern = strings.ToLower(pattern)
+	origin = strings.ToLower(origin)
+
+	if pattern == "*" {
+		return true
+	}
+
+	parts := strings.Split(pattern, "*")
+
+	if len(parts) == 1 {
+		return pattern == origin
+	}
+
+	if strings.HasPrefix(origin, parts[0]) == false {
+		return false
+	}
+
+	origin = origin[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(origin, part)
+		if i < 0 {
+			return false
+		}
+		origin = origin[i+len(part):]
+	}
+
+	return strings.HasSuffix(origin, parts[len(parts)-1])
+}
+
+// Returns an error if the policy would let any site read responses that
+// carry the user's credentials.
+func (policy *CORSPolicy) validate() error {
+	if policy.Credentials == false {
+		return nil
+	}
+	for _, pattern := range policy.Origins {
+		if strings.TrimSpace(pattern) == "*" {
+			return fmt.Errorf(`CORS credentials can't be allowed for every origin ("*"), list the origins instead.`)
+		}
+	}
+	return nil
+}
+
+// Returns true if the origin may send requests.
+func (policy *CORSPolicy) allowsOrigin(origin string) bool {
+	for _, pattern := range policy.Origins {
+		// Never reached for valid policies, see validate().
+		if policy.Credentials == true && pattern == "*" {
+			continue
+		}
+		if matchOrigin(pattern, origin) == true {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the method may be used.
+func (policy *CORSPolicy) allowsMethod(method string) bool {
+	methods := policy.Methods
+
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	for _, allowed := range methods {
+		if strings.EqualFold(allowed, method) == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if all the requested headers may be sent.
+func (policy *CORSPolicy) allowsHeaders(requested []string) bool {
+	if len(policy.Headers) == 0 {
+		return true
+	}
+
+	for _, name := range requested {
+		allowed := false
+		for _, header := range policy.Headers {
+			if strings.EqualFold(header, name) == true {
+				allowed = true
+				break
+			}
+		}
+		if allowed == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Splits the Access-Control-Request-Headers list.
+func requestedHeaders(request *http.Request) []string {
+	names := []string{}
+
+	for _, value := range request.Header["Access-Control-Request-Headers"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// Returns true if the request is a CORS preflight.
+func isPreflight(request *http.Request) bool {
+	return request.Method == "OPTIONS" && request.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Answers a cross-origin request, returns the status for preflights.
+func (policy *CORSPolicy) handle(context *Context) int {
+	request := context.Request
+	header := context.Writer.Header()
+
+	origin := request.Header.Get("Origin")
+
+	header.Add("Vary", "Origin")
+
+	preflight := isPreflight(request)
+
+	if preflight == true {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	if origin == "" || policy.allowsOrigin(origin) == false {
+		if preflight == true {
+			return 403
+		}
+		return 0
+	}
+
+	method := request.Header.Get("Access-Control-Request-Method")
+	headers := requestedHeaders(request)
+
+	if preflight == true && (policy.allowsMethod(method) == false || policy.allowsHeaders(headers) == false) {
+		return 403
+	}
+
+	if policy.Credentials == false && policy.allowsOrigin("*") == true {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if policy.Credentials == true {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if preflight == false {
+		if len(policy.Expose) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(policy.Expose, ", "))
+		}
+		return 0
+	}
+
+	methods := policy.Methods
+
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+
+	header.Set("Access-Control-Allow-Methods", strings.ToUpper(strings.Join(methods, ", ")))
+
+	if len(policy.Headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+	} else if len(headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+
+	if policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", fmt.Sprintf("%d", policy.MaxAge))
+	}
+
+	return 204
+}
+
+// Returns a Filter that answers CORS preflights with 204 No Content and adds
+// the Access-Control-* headers to cross-origin requests. It should be added
+// before any filter that requires authentication, since browsers don't send
+// credentials on preflights. It panics if the policy allows credentials for
+// every origin.
+func CORS(policy CORSPolicy) Filter {
+	if err := policy.validate(); err != nil {
+		panic(err.Error())
+	}
+	return policy.handle
+}
+
+// Reads a CORSPolicy from a settings map.
+func corsPolicy(settings map[string]interface{}) CORSPolicy {
+	list := func(name string) []string {
+		result := []string{}
+		for _, item := range to.List(settings[name]) {
+			result = append(result, to.String(item))
+		}
+		return result
+	}
+
+	policy := CORSPolicy{
+		Origins:     list("origins"),
+		Methods:     list("methods"),
+		Headers:     list("headers"),
+		Expose:      list("expose"),
+		Credentials: to.Bool(settings["credentials"]),
+		MaxAge:      int(to.Int64(settings["max_age"])),
+	}
+
+	// An invalid policy allows no cross-origin requests at all.
+	if err := policy.validate(); err != nil {
+		logger.Error("Ignoring CORS policy.", "path", to.String(settings["path"]), "error", err)
+		return CORSPolicy{}
+	}
+
+	return policy
+}
+
+// Returns a Filter for the cors section of settings.yaml, or nil if it's
+// not configured. Each request is handled by the policy of the longest
+// route it falls under, the top level policy applies to the whole site.
+// Preflights for routes without a policy get a 204 without CORS headers, so
+// browsers refuse the request and no model runs.
+func corsSettings() Filter {
+	settings := to.Map(config.Get("cors"))
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	paths := []string{}
+	policies := map[string]CORSPolicy{}
+
+	if settings["origins"] != nil {
+		paths = append(paths, "/")
+		policies["/"] = corsPolicy(settings)
+	}
+
+	for _, item := range to.List(settings["routes"]) {
+		rule := to.Map(item)
+		path := fmt.Sprintf("/%s", strings.Trim(strings.ToLower(to.String(rule["path"])), "/"))
+		paths = append(paths, path)
+		policies[path] = corsPolicy(rule)
+	}
+
+	return func(context *Context) int {
+		match := ""
+
+		for _, path := range paths {
+			if underRoute(path, context.Request.URL.Path) == true && len(path) > len(match) {
+				match = path
+			}
+		}
+
+		if match == "" {
+			if isPreflight(context.Request) == true {
+				return 204
+			}
+			return 0
+		}
+
+		policy := policies[match]
+
+		return policy.handle(context)
+	}
+}
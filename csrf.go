@@ -0,0 +1,137 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/astrata/tango/body"
+	"github.com/astrata/tango/token"
+	"html/template"
+	"net/http"
+)
+
+const (
+	// Session key for the CSRF token.
+	csrfKey = "_csrf"
+	// Form field that carries the token.
+	CSRFField = "csrf_token"
+	// Header that carries the token (for XHR requests).
+	CSRFHeader = "X-CSRF-Token"
+)
+
+func init() {
+	// {{csrf_token}}
+	body.TemplateFunc("csrf_token", func(request *http.Request) interface{} {
+		if context := contextOf(request); context != nil {
+			return context.CSRFToken()
+		}
+		return ""
+	})
+	// <form method="post">{{csrf_field}}...</form>
+	body.TemplateFunc("csrf_field", func(request *http.Request) interface{} {
+		if context := contextOf(request); context != nil {
+			return context.CSRFField()
+		}
+		return template.HTML("")
+	})
+}
+
+// Returns the CSRF token of the current session, a new one is generated if
+// the session does not have one.
+func (context *Context) CSRFToken() string {
+	sess := context.Session()
+
+	if value, ok := sess.Get(csrfKey).(string); ok && value != "" {
+		return value
+	}
+
+	value, err := token.URLSafe(32)
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	sess.Set(csrfKey, value)
+
+	return value
+}
+
+// Returns a hidden form field with the CSRF token.
+func (context *Context) CSRFField() template.HTML {
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, CSRFField, template.HTMLEscapeString(context.CSRFToken())))
+}
+
+// Returns true if the request carries the CSRF token of the session, in the
+// csrf_token field or the X-CSRF-Token header.
+func (context *Context) checkCSRF() bool {
+	if _, ok := context.Cookies[sessionName()]; ok == false {
+		return false
+	}
+
+	expected, _ := context.Session().Get(csrfKey).(string)
+
+	if expected == "" {
+		return false
+	}
+
+	given := context.Request.Header.Get(CSRFHeader)
+
+	if given == "" {
+		if values, ok := context.Params[CSRFField].([]string); ok && len(values) > 0 {
+			given = values[0]
+		}
+	}
+
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// Returns a Filter that rejects requests other than GET, HEAD, OPTIONS and
+// TRACE without a valid CSRF token with 403. Requests to any of the exempt
+// routes (and their subpaths) are not checked. The filter is added to "/"
+// when csrf/enabled is true in settings.yaml, with csrf/exempt as the exempt
+// routes.
+func CSRF(exempt ...string) Filter {
+	return func(context *Context) int {
+		switch context.Request.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+			return 0
+		}
+
+		for _, route := range exempt {
+			if underRoute(route, context.Request.URL.Path) == true {
+				return 0
+			}
+		}
+
+		if context.checkCSRF() == false {
+			return 403
+		}
+
+		return 0
+	}
+}
@@ -0,0 +1,91 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"encoding/gob"
+	"github.com/astrata/tango/body"
+	"net/http"
+)
+
+// Session key for pending flash messages.
+const flashKey = "_flashes"
+
+// A message for the next page the user sees, e.g. after a redirect.
+type Flash struct {
+	// Kind of message, like "success" or "error".
+	Kind    string
+	Message string
+}
+
+func init() {
+	// Sessions may be saved with encoding/gob.
+	gob.Register([]Flash{})
+
+	// {{range flashes}}<p class="{{.Kind}}">{{.Message}}</p>{{end}}
+	body.TemplateFunc("flashes", func(request *http.Request) interface{} {
+		if context := contextOf(request); context != nil {
+			return context.Flashes()
+		}
+		return []Flash{}
+	})
+}
+
+// Stores a message in the session so it can be displayed by the next
+// request, e.g.:
+//
+//	self.Context.Flash("success", "Saved!")
+//	self.Context.Redirect("/posts")
+func (context *Context) Flash(kind string, message string) {
+	sess := context.Session()
+
+	flashes, _ := sess.Get(flashKey).([]Flash)
+
+	sess.Set(flashKey, append(flashes, Flash{kind, message}))
+}
+
+// Returns the pending flash messages and removes them from the session.
+func (context *Context) Flashes() []Flash {
+	if context.session == nil {
+		// Not starting a session just to find out there's nothing in it.
+		if _, ok := context.Cookies[sessionName()]; ok == false {
+			return []Flash{}
+		}
+	}
+
+	sess := context.Session()
+
+	flashes, _ := sess.Get(flashKey).([]Flash)
+
+	if flashes == nil {
+		return []Flash{}
+	}
+
+	sess.Delete(flashKey)
+
+	return flashes
+}
@@ -0,0 +1,61 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"github.com/astrata/tango/jwt"
+)
+
+// Returns a Filter that requires a JWT bearer token accepted by the given
+// validator, e.g.:
+//
+//	validator := &jwt.Validator{Keys: jwt.KeySet{jwt.NewHS256("2013-01", secret)}, Issuer: "example.com"}
+//	app.Filter("/api", tango.JWTAuth("API", validator))
+//
+// The "sub" claim becomes the user name and every claim is available with
+// Context.Claims().
+func JWTAuth(realm string, validator *jwt.Validator) Filter {
+	return func(context *Context) int {
+		token := context.AuthBearer()
+
+		if token != "" {
+			claims, err := validator.Verify(token)
+			if err == nil {
+				context.claims = claims
+				context.SetUser(claims.Subject())
+				return 0
+			}
+		}
+
+		return bearerChallenge(context, realm, token != "")
+	}
+}
+
+// Returns the claims of the JWT verified by JWTAuth(), or nil.
+func (context *Context) Claims() jwt.Claims {
+	return context.claims
+}
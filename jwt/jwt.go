@@ -0,0 +1,273 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+/*
+  This package issues and verifies JSON Web Tokens (RFC 7519) signed with
+  HS256, RS256 or EdDSA.
+*/
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Verification errors.
+var (
+	ErrMalformed   = errors.New("Malformed token.")
+	ErrUnknownKey  = errors.New("No key can verify this token.")
+	ErrSignature   = errors.New("Invalid token signature.")
+	ErrExpired     = errors.New("Token has expired.")
+	ErrNotYetValid = errors.New("Token is not valid yet.")
+	ErrIssuer      = errors.New("Unexpected token issuer.")
+	ErrAudience    = errors.New("Token is not meant for this audience.")
+	ErrClaim       = errors.New("Malformed token claim.")
+)
+
+// Token claims.
+type Claims map[string]interface{}
+
+// Returns a string claim.
+func (self Claims) String(name string) string {
+	value, _ := self[name].(string)
+	return value
+}
+
+// Returns a NumericDate claim (like "exp"), ok is false if it's missing or
+// is not a number.
+func (self Claims) Time(name string) (time.Time, bool) {
+	value, err := self.date(name)
+	if err != nil || value == nil {
+		return time.Time{}, false
+	}
+	return *value, true
+}
+
+// Returns a NumericDate claim, nil if it's missing and ErrClaim if it's not
+// a number.
+func (self Claims) date(name string) (*time.Time, error) {
+	var value time.Time
+
+	switch claim := self[name].(type) {
+	case nil:
+		if _, ok := self[name]; ok == false {
+			return nil, nil
+		}
+		return nil, ErrClaim
+	case float64:
+		value = time.Unix(int64(claim), 0)
+	case int64:
+		value = time.Unix(claim, 0)
+	case int:
+		value = time.Unix(int64(claim), 0)
+	default:
+		return nil, ErrClaim
+	}
+
+	return &value, nil
+}
+
+// Returns the "sub" claim.
+func (self Claims) Subject() string {
+	return self.String("sub")
+}
+
+// Returns the "iss" claim.
+func (self Claims) Issuer() string {
+	return self.String("iss")
+}
+
+// Returns the "aud" claim, which may be a string or a list.
+func (self Claims) Audience() []string {
+	switch value := self["aud"].(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []interface{}:
+		audience := []string{}
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return []string{}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Signs the given claims with a key.
+func Sign(claims Claims, key *Key) (string, error) {
+	head, err := json.Marshal(header{Alg: key.Algorithm, Typ: "JWT", Kid: key.Id})
+
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+
+	if err != nil {
+		return "", err
+	}
+
+	message := encoding.EncodeToString(head) + "." + encoding.EncodeToString(payload)
+
+	signature, err := key.sign([]byte(message))
+
+	if err != nil {
+		return "", err
+	}
+
+	return message + "." + encoding.EncodeToString(signature), nil
+}
+
+// Signs the given claims with the signing key of the set.
+func (self KeySet) Sign(claims Claims) (string, error) {
+	key, err := self.signer()
+
+	if err != nil {
+		return "", err
+	}
+
+	return Sign(claims, key)
+}
+
+// Verifies tokens and checks their claims.
+type Validator struct {
+	// Keys that may have signed the tokens.
+	Keys KeySet
+	// Required "iss", if not empty.
+	Issuer string
+	// Required "aud", if not empty.
+	Audience string
+	// Tolerance for clock differences when checking "exp" and "nbf".
+	Leeway time.Duration
+}
+
+// Checks the signature of a token, its expiration and (if set) its issuer
+// and audience, and returns its claims.
+func (self *Validator) Verify(token string) (Claims, error) {
+	chunks := strings.Split(token, ".")
+
+	if len(chunks) != 3 {
+		return nil, ErrMalformed
+	}
+
+	head := header{}
+
+	data, err := encoding.DecodeString(chunks[0])
+
+	if err != nil || json.Unmarshal(data, &head) != nil {
+		return nil, ErrMalformed
+	}
+
+	signature, err := encoding.DecodeString(chunks[2])
+
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	keys := self.Keys.candidates(head.Alg, head.Kid)
+
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+
+	message := []byte(chunks[0] + "." + chunks[1])
+
+	verified := false
+
+	for _, key := range keys {
+		if key.verify(message, signature) == true {
+			verified = true
+			break
+		}
+	}
+
+	if verified == false {
+		return nil, ErrSignature
+	}
+
+	claims := Claims{}
+
+	data, err = encoding.DecodeString(chunks[1])
+
+	if err != nil || json.Unmarshal(data, &claims) != nil {
+		return nil, ErrMalformed
+	}
+
+	now := time.Now()
+
+	exp, err := claims.date("exp")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if exp != nil && now.After(exp.Add(self.Leeway)) {
+		return nil, ErrExpired
+	}
+
+	nbf, err := claims.date("nbf")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nbf != nil && now.Add(self.Leeway).Before(*nbf) {
+		return nil, ErrNotYetValid
+	}
+
+	if self.Issuer != "" && claims.Issuer() != self.Issuer {
+		return nil, ErrIssuer
+	}
+
+	if self.Audience != "" {
+		found := false
+		for _, audience := range claims.Audience() {
+			if audience == self.Audience {
+				found = true
+				break
+			}
+		}
+		if found == false {
+			return nil, ErrAudience
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,121 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Builds an unsigned token with the given header and claims.
+func unsigned(head map[string]interface{}, claims Claims, signature string) string {
+	h, _ := json.Marshal(head)
+	c, _ := json.Marshal(claims)
+	return encoding.EncodeToString(h) + "." + encoding.EncodeToString(c) + "." + signature
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := NewHS256("hs", []byte("secret"))
+	rs := NewRS256("rs", rsaKey)
+	ed := NewEdDSA("ed", edKey)
+
+	now := time.Now().Unix()
+
+	sign := func(key *Key, claims Claims) string {
+		token, err := Sign(claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name      string
+		validator Validator
+		token     string
+		err       error
+	}{
+		{"hs256", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"sub": "a"}), nil},
+		{"rs256", Validator{Keys: KeySet{rs}}, sign(rs, Claims{"sub": "a"}), nil},
+		{"eddsa", Validator{Keys: KeySet{ed}}, sign(ed, Claims{"sub": "a"}), nil},
+		{"rotated key", Validator{Keys: KeySet{NewHS256("new", []byte("other")), hs}}, sign(hs, Claims{}), nil},
+		{"wrong secret", Validator{Keys: KeySet{NewHS256("hs", []byte("other"))}}, sign(hs, Claims{}), ErrSignature},
+		{"alg none", Validator{Keys: KeySet{hs}}, unsigned(map[string]interface{}{"alg": "none"}, Claims{}, ""), ErrUnknownKey},
+		{"alg confusion", Validator{Keys: KeySet{NewRS256Public("rs", &rsaKey.PublicKey)}}, sign(NewHS256("rs", []byte("public")), Claims{}), ErrUnknownKey},
+		{"unknown kid", Validator{Keys: KeySet{hs}}, sign(NewHS256("other", []byte("secret")), Claims{}), ErrUnknownKey},
+		{"two chunks", Validator{Keys: KeySet{hs}}, "a.b", ErrMalformed},
+		{"expired", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"exp": now - 60}), ErrExpired},
+		{"expired within leeway", Validator{Keys: KeySet{hs}, Leeway: 2 * time.Minute}, sign(hs, Claims{"exp": now - 60}), nil},
+		{"not expired", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"exp": now + 60}), nil},
+		{"malformed exp", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"exp": "tomorrow"}), ErrClaim},
+		{"null exp", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"exp": nil}), ErrClaim},
+		{"not yet valid", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"nbf": now + 60}), ErrNotYetValid},
+		{"nbf within leeway", Validator{Keys: KeySet{hs}, Leeway: 2 * time.Minute}, sign(hs, Claims{"nbf": now + 60}), nil},
+		{"malformed nbf", Validator{Keys: KeySet{hs}}, sign(hs, Claims{"nbf": "now"}), ErrClaim},
+		{"issuer", Validator{Keys: KeySet{hs}, Issuer: "tango"}, sign(hs, Claims{"iss": "tango"}), nil},
+		{"wrong issuer", Validator{Keys: KeySet{hs}, Issuer: "tango"}, sign(hs, Claims{"iss": "other"}), ErrIssuer},
+		{"audience list", Validator{Keys: KeySet{hs}, Audience: "api"}, sign(hs, Claims{"aud": []string{"web", "api"}}), nil},
+		{"wrong audience", Validator{Keys: KeySet{hs}, Audience: "api"}, sign(hs, Claims{"aud": "web"}), ErrAudience},
+	}
+
+	for _, test := range tests {
+		_, err := test.validator.Verify(test.token)
+		if err != test.err {
+			t.Errorf("%s: got error %v, expecting %v.", test.name, err, test.err)
+		}
+	}
+}
+
+func TestClaimsTime(t *testing.T) {
+	claims := Claims{"exp": float64(1000), "nbf": "soon"}
+
+	if exp, ok := claims.Time("exp"); ok == false || exp.Unix() != 1000 {
+		t.Fatalf("Expecting exp to be read.")
+	}
+
+	if _, ok := claims.Time("nbf"); ok == true {
+		t.Fatalf("Expecting a malformed nbf to be ignored.")
+	}
+
+	if _, ok := claims.Time("iat"); ok == true {
+		t.Fatalf("Expecting a missing iat to be ignored.")
+	}
+}
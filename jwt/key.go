@@ -0,0 +1,169 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Supported algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// A signing or verification key. Keys only verify tokens signed with their
+// own algorithm.
+type Key struct {
+	// Key ID, sent as "kid" in the token header.
+	Id        string
+	Algorithm string
+
+	secret  []byte
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// Returns a key for HMAC SHA-256 signatures.
+func NewHS256(id string, secret []byte) *Key {
+	return &Key{Id: id, Algorithm: HS256, secret: secret}
+}
+
+// Returns a key for RSA SHA-256 signatures, it can sign and verify.
+func NewRS256(id string, private *rsa.PrivateKey) *Key {
+	return &Key{Id: id, Algorithm: RS256, private: private, public: &private.PublicKey}
+}
+
+// Returns a key that can only verify RSA SHA-256 signatures.
+func NewRS256Public(id string, public *rsa.PublicKey) *Key {
+	return &Key{Id: id, Algorithm: RS256, public: public}
+}
+
+// Returns a key for Ed25519 signatures, it can sign and verify.
+func NewEdDSA(id string, private ed25519.PrivateKey) *Key {
+	return &Key{Id: id, Algorithm: EdDSA, private: private, public: private.Public()}
+}
+
+// Returns a key that can only verify Ed25519 signatures.
+func NewEdDSAPublic(id string, public ed25519.PublicKey) *Key {
+	return &Key{Id: id, Algorithm: EdDSA, public: public}
+}
+
+// Returns true if the key can sign tokens.
+func (self *Key) CanSign() bool {
+	switch self.Algorithm {
+	case HS256:
+		return len(self.secret) > 0
+	case RS256, EdDSA:
+		return self.private != nil
+	}
+	return false
+}
+
+// Signs a message.
+func (self *Key) sign(message []byte) ([]byte, error) {
+	if self.CanSign() == false {
+		return nil, fmt.Errorf("Key %q can't sign.", self.Id)
+	}
+
+	switch self.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, self.secret)
+		mac.Write(message)
+		return mac.Sum(nil), nil
+	case RS256:
+		digest := sha256.Sum256(message)
+		return self.private.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case EdDSA:
+		return self.private.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+
+	return nil, fmt.Errorf("Unsupported algorithm %s.", self.Algorithm)
+}
+
+// Checks the signature of a message.
+func (self *Key) verify(message []byte, signature []byte) bool {
+	switch self.Algorithm {
+	case HS256:
+		if len(self.secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, self.secret)
+		mac.Write(message)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		public, ok := self.public.(*rsa.PublicKey)
+		if ok == false {
+			return false
+		}
+		digest := sha256.Sum256(message)
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	case EdDSA:
+		public, ok := self.public.(ed25519.PublicKey)
+		if ok == false {
+			return false
+		}
+		return ed25519.Verify(public, message, signature)
+	}
+	return false
+}
+
+// A set of keys, for rotation. New tokens are signed with the first key
+// that can sign, tokens are verified with any key of the set.
+type KeySet []*Key
+
+// Returns the key used for signing.
+func (self KeySet) signer() (*Key, error) {
+	for _, key := range self {
+		if key.CanSign() == true {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("No key can sign.")
+}
+
+// Returns the keys that may have signed a token with the given header.
+func (self KeySet) candidates(algorithm string, id string) []*Key {
+	keys := []*Key{}
+	for _, key := range self {
+		if key.Algorithm != algorithm {
+			continue
+		}
+		if id != "" && key.Id != id {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,131 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+/*
+  This package implements a leveled logger with key/value fields.
+
+  Messages are written with the Logger set with Set(), which apps may
+  replace with their own implementation:
+
+	logger.Info("User signed in.", "user", name, "attempts", n)
+*/
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Severity of a message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// Returns the name of the level.
+func (self Level) String() string {
+	if self < LevelDebug || self > LevelError {
+		return fmt.Sprintf("LEVEL(%d)", int(self))
+	}
+	return levelNames[self]
+}
+
+// Returns the level with the given name ("debug", "info", "warn" or
+// "error").
+func ParseLevel(name string) (Level, error) {
+	for i, level := range levelNames {
+		if strings.EqualFold(name, level) || (level == "WARN" && strings.EqualFold(name, "warning")) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("Unknown log level %q.", name)
+}
+
+// A leveled logger. Messages are followed by key/value pairs, keys are
+// strings:
+//
+//	log.Warn("Slow query.", "table", name, "ms", elapsed)
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+	// Returns a Logger that adds the given pairs to every message.
+	With(kv ...interface{}) Logger
+}
+
+var (
+	current Logger = New(os.Stderr, LevelInfo, Text)
+	mu      sync.RWMutex
+)
+
+// Replaces the package logger.
+func Set(logger Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = logger
+}
+
+// Returns the package logger.
+func Get() Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Logs a message with the package logger.
+func Debug(msg string, kv ...interface{}) {
+	Get().Debug(msg, kv...)
+}
+
+// Logs a message with the package logger.
+func Info(msg string, kv ...interface{}) {
+	Get().Info(msg, kv...)
+}
+
+// Logs a message with the package logger.
+func Warn(msg string, kv ...interface{}) {
+	Get().Warn(msg, kv...)
+}
+
+// Logs a message with the package logger.
+func Error(msg string, kv ...interface{}) {
+	Get().Error(msg, kv...)
+}
+
+// Logs a message with the Error level and stops the program.
+func Fatal(msg string, kv ...interface{}) {
+	Get().Error(msg, kv...)
+	os.Exit(1)
+}
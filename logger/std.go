@@ -0,0 +1,202 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Output format of the standard Logger.
+type Format int
+
+const (
+	// time LEVEL message key=value ...
+	Text Format = iota
+	// One JSON object per line.
+	JSON
+)
+
+// Returns the format with the given name ("text" or "json").
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "", "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	}
+	return Text, fmt.Errorf("Unknown log format %q.", name)
+}
+
+// Destination shared by a logger and the loggers derived from it.
+type output struct {
+	mu     sync.Mutex
+	out    io.Writer
+	level  Level
+	format Format
+}
+
+// The standard Logger.
+type std struct {
+	*output
+	fields []interface{}
+}
+
+// Returns a Logger that writes messages of the given level or higher into
+// out.
+func New(out io.Writer, level Level, format Format) Logger {
+	return &std{output: &output{out: out, level: level, format: format}}
+}
+
+func (self *std) Debug(msg string, kv ...interface{}) {
+	self.write(LevelDebug, msg, kv)
+}
+
+func (self *std) Info(msg string, kv ...interface{}) {
+	self.write(LevelInfo, msg, kv)
+}
+
+func (self *std) Warn(msg string, kv ...interface{}) {
+	self.write(LevelWarn, msg, kv)
+}
+
+func (self *std) Error(msg string, kv ...interface{}) {
+	self.write(LevelError, msg, kv)
+}
+
+func (self *std) With(kv ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(self.fields)+len(kv))
+	fields = append(fields, self.fields...)
+	fields = append(fields, kv...)
+	return &std{output: self.output, fields: fields}
+}
+
+// Returns the key of a pair, odd values get a generated key.
+func pairKey(kv []interface{}, i int) string {
+	if key, ok := kv[i].(string); ok {
+		return key
+	}
+	return fmt.Sprintf("%v", kv[i])
+}
+
+// Returns the value of a pair, errors are logged by their message.
+func pairValue(kv []interface{}, i int) interface{} {
+	if i >= len(kv) {
+		return nil
+	}
+	if err, ok := kv[i].(error); ok {
+		return err.Error()
+	}
+	return kv[i]
+}
+
+func (self *std) write(level Level, msg string, kv []interface{}) {
+	if level < self.level {
+		return
+	}
+
+	pairs := make([]interface{}, 0, len(self.fields)+len(kv))
+	pairs = append(pairs, self.fields...)
+	pairs = append(pairs, kv...)
+
+	now := time.Now()
+
+	buf := bytes.NewBuffer(nil)
+
+	if self.format == JSON {
+		buf.WriteString(`{"time":`)
+		writeJSON(buf, now.Format(time.RFC3339Nano))
+		buf.WriteString(`,"level":`)
+		writeJSON(buf, level.String())
+		buf.WriteString(`,"msg":`)
+		writeJSON(buf, msg)
+		for i := 0; i < len(pairs); i += 2 {
+			buf.WriteByte(',')
+			writeJSON(buf, pairKey(pairs, i))
+			buf.WriteByte(':')
+			writeJSON(buf, pairValue(pairs, i+1))
+		}
+		buf.WriteString("}\n")
+	} else {
+		buf.WriteString(now.Format("2006/01/02 15:04:05"))
+		buf.WriteByte(' ')
+		buf.WriteString(level.String())
+		buf.WriteByte(' ')
+		buf.WriteString(msg)
+		for i := 0; i < len(pairs); i += 2 {
+			buf.WriteByte(' ')
+			buf.WriteString(pairKey(pairs, i))
+			buf.WriteByte('=')
+			buf.WriteString(textValue(pairValue(pairs, i+1)))
+		}
+		buf.WriteByte('\n')
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.out.Write(buf.Bytes())
+}
+
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	buf.Write(data)
+}
+
+// Formats a value for the text format, quoting it if needed.
+func textValue(value interface{}) string {
+	var text string
+
+	switch v := value.(type) {
+	case string:
+		text = v
+	case fmt.Stringer:
+		text = v.String()
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+
+	if text == "" {
+		return `""`
+	}
+
+	for _, c := range text {
+		if c <= ' ' || c == '"' || c == '=' || c > '~' {
+			return strconv.Quote(text)
+		}
+	}
+
+	return text
+}
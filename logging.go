@@ -0,0 +1,79 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"github.com/astrata/tango/clf"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/gosexy/to"
+	"io"
+	"os"
+)
+
+func init() {
+	setupLogger()
+}
+
+// Configures the framework logger from the log section of settings.yaml, apps
+// may replace it afterwards with logger.Set().
+func setupLogger() {
+	if config.Get("log") == nil {
+		return
+	}
+
+	level, err := logger.ParseLevel(to.String(config.Get("log/level")))
+
+	if err != nil && to.String(config.Get("log/level")) != "" {
+		logger.Warn("Ignoring log/level.", "error", err)
+	}
+
+	format, err := logger.ParseFormat(to.String(config.Get("log/format")))
+
+	if err != nil {
+		logger.Warn("Ignoring log/format.", "error", err)
+	}
+
+	var out io.Writer
+
+	switch output := to.String(config.Get("log/output")); output {
+	case "", "stderr":
+		out = os.Stderr
+	case "stdout":
+		out = os.Stdout
+	default:
+		file, err := clf.OpenFile(output)
+		if err != nil {
+			logger.Warn("Could not open log file, logging to stderr.", "file", output, "error", err)
+			out = os.Stderr
+		} else {
+			out = file
+		}
+	}
+
+	logger.Set(logger.New(out, level, format))
+}
@@ -0,0 +1,191 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/astrata/tango/body"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/astrata/tango/metrics"
+	"github.com/gosexy/to"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Default path for the metrics endpoint.
+const defaultMetricsPath = "/metrics"
+
+// Route label of requests that didn't reach a model.
+const unroutedLabel = "none"
+
+// Built-in metrics, collected by Server.Route().
+var (
+	metricRequests = metrics.NewCounter(
+		"tango_http_requests_total",
+		"Requests served, by route, method and status.",
+		"route", "method", "status",
+	)
+	metricDuration = metrics.NewHistogram(
+		"tango_http_request_duration_seconds",
+		"Time spent serving requests.",
+		nil,
+		"route", "method",
+	)
+	metricSize = metrics.NewHistogram(
+		"tango_http_response_size_bytes",
+		"Size of response bodies.",
+		metrics.ExponentialBuckets(100, 10, 7),
+		"route", "method",
+	)
+	metricInFlight = metrics.NewGauge(
+		"tango_http_requests_in_flight",
+		"Requests being served.",
+	)
+	metricPanics = metrics.NewCounter(
+		"tango_http_panics_total",
+		"Requests that panicked, by route.",
+		"route",
+	)
+)
+
+// Exposes the Default metrics registry.
+type metricsModel struct{}
+
+// Returns the metrics in the Prometheus text format.
+func (self *metricsModel) Index() body.Body {
+	buf := bytes.NewBuffer(nil)
+
+	if err := metrics.Default.Write(buf); err != nil {
+		logger.Error("Could not write metrics.", "error", err)
+	}
+
+	content := body.Text()
+	content.Header().Set("Content-type", metrics.ContentType)
+	content.Set(buf)
+
+	return content
+}
+
+// Adds the metrics endpoint if metrics/enabled is set in settings.yaml. It
+// can be protected with metrics/user and metrics/password (Basic
+// authentication) or metrics/token (Bearer authentication), if both are set
+// either one is accepted.
+func (s *Server) metricsSettings() {
+	if to.Bool(config.Get("metrics/enabled")) == false {
+		return
+	}
+
+	path := to.String(config.Get("metrics/path"))
+
+	if path == "" {
+		path = defaultMetricsPath
+	}
+
+	var basic, bearer Filter
+
+	if user := to.String(config.Get("metrics/user")); user != "" {
+		basic = BasicAuth("metrics", BasicCredentials(map[string]string{
+			user: to.String(config.Get("metrics/password")),
+		}))
+	}
+
+	if token := to.String(config.Get("metrics/token")); token != "" {
+		bearer = BearerAuth("metrics", BearerTokens(map[string]string{
+			token: "metrics",
+		}))
+	}
+
+	switch {
+	case basic != nil && bearer != nil:
+		// Either scheme is accepted, a request carries only one of them.
+		s.Filter(path, func(context *Context) int {
+			if scheme, _ := context.authorization(); scheme == "bearer" {
+				return bearer(context)
+			}
+			if status := basic(context); status != 0 {
+				return bearerChallenge(context, "metrics", false)
+			}
+			return 0
+		})
+	case basic != nil:
+		s.Filter(path, basic)
+	case bearer != nil:
+		s.Filter(path, bearer)
+	}
+
+	s.Connect(path, &metricsModel{})
+}
+
+// Returns the route label of the current request.
+func (context *Context) routeLabel() string {
+	if context.route == "" {
+		return unroutedLabel
+	}
+	return context.route
+}
+
+// Returns the method label of the current request, unknown methods share
+// one label so clients can't create new series at will.
+func (context *Context) methodLabel() string {
+	switch method := context.Request.Method; method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS":
+		return method
+	}
+	return "other"
+}
+
+// Records the metrics of a finished request.
+func (context *Context) observe(status int, size int) {
+	route := context.routeLabel()
+	method := context.methodLabel()
+
+	metricRequests.With(route, method, fmt.Sprintf("%d", status)).Inc()
+	metricDuration.With(route, method).Observe(time.Since(context.started).Seconds())
+	metricSize.With(route, method).Observe(float64(size))
+}
+
+// Counts a panic of the current request and answers with 500 if nothing was
+// sent yet, the panic is logged with its stack. http.ErrAbortHandler is
+// passed on, it's how handlers abort a response on purpose.
+func (context *Context) recoverPanic(err interface{}) {
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+
+	metricPanics.With(context.routeLabel()).Inc()
+
+	context.Log().Error("Panic.", "error", fmt.Sprintf("%v", err), "stack", string(debug.Stack()))
+
+	if context.response.started == false && context.response.hijacked == false {
+		context.errorPage(500)
+	}
+
+	context.finish(500, 0)
+}
@@ -0,0 +1,142 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Default histogram buckets, in seconds, for request latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counts observations, like request latencies, into buckets.
+type Histogram struct {
+	family *family
+}
+
+// The buckets of a Histogram for a label combination.
+type HistogramValue struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// Returns exponential buckets: start, start*factor, start*factor^2...
+func ExponentialBuckets(start float64, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start * math.Pow(factor, float64(i))
+	}
+	return buckets
+}
+
+// Registers a new histogram with the given upper bounds (DefaultBuckets if
+// nil) and label names.
+func (self *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64{}, buckets...)
+
+	sort.Float64s(buckets)
+
+	f := &family{name: name, help: help, kind: "histogram", labels: labels}
+
+	f.create = func() interface{} {
+		return &HistogramValue{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}
+
+	f.write = func(w *bufio.Writer, name string, labels string, value interface{}) {
+		value.(*HistogramValue).write(w, name, labels)
+	}
+
+	self.register(f)
+
+	return &Histogram{f}
+}
+
+// Registers a new histogram in the Default registry.
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// Returns the buckets for the given label values.
+func (self *Histogram) With(values ...string) *HistogramValue {
+	return self.family.with(values).(*HistogramValue)
+}
+
+// Adds an observation to a histogram without labels.
+func (self *Histogram) Observe(value float64) {
+	self.With().Observe(value)
+}
+
+// Adds an observation.
+func (self *HistogramValue) Observe(value float64) {
+	i := sort.SearchFloat64s(self.buckets, value)
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if i < len(self.counts) {
+		self.counts[i]++
+	}
+
+	self.sum += value
+	self.count++
+}
+
+func (self *HistogramValue) write(w *bufio.Writer, name string, labels string) {
+	self.mu.Lock()
+	counts := append([]uint64{}, self.counts...)
+	sum := self.sum
+	count := self.count
+	self.mu.Unlock()
+
+	prefix := labels
+
+	if prefix != "" {
+		prefix += ","
+	}
+
+	var cumulative uint64
+
+	for i, bound := range self.buckets {
+		cumulative += counts[i]
+		writeSample(w, name+"_bucket", fmt.Sprintf(`%sle="%s"`, prefix, formatValue(bound)), float64(cumulative))
+	}
+
+	writeSample(w, name+"_bucket", prefix+`le="+Inf"`, float64(count))
+	writeSample(w, name+"_sum", labels, sum)
+	writeSample(w, name+"_count", labels, float64(count))
+}
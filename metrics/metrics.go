@@ -0,0 +1,234 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+/*
+  This package collects counters, gauges and histograms and writes them in
+  the Prometheus text exposition format [1].
+
+	var jobs = metrics.NewCounter("app_jobs_total", "Jobs run.", "queue")
+
+	jobs.With("mail").Inc()
+
+  [1]: https://prometheus.io/docs/instrumenting/exposition_formats/
+*/
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// A set of metrics that are exposed together.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// Allocates a new &Registry{}.
+func NewRegistry() *Registry {
+	self := &Registry{}
+	self.families = make(map[string]*family)
+	return self
+}
+
+// Registry used by the package functions and exposed by the server.
+var Default = NewRegistry()
+
+// A metric with all its label combinations.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu       sync.Mutex
+	children map[string]*child
+
+	// Allocates the value of a new label combination.
+	create func() interface{}
+	// Writes the samples of a value.
+	write func(w *bufio.Writer, name string, labels string, value interface{})
+}
+
+type child struct {
+	labels string
+	value  interface{}
+}
+
+// Returns true if name is a valid metric or label name.
+func validName(name string, label bool) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c == ':' && label == false:
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Adds a family, names must be valid and unique within the registry.
+func (self *Registry) register(f *family) {
+	if validName(f.name, false) == false {
+		panic(fmt.Sprintf("Invalid metric name %q.", f.name))
+	}
+
+	for _, label := range f.labels {
+		if validName(label, true) == false || label == "le" {
+			panic(fmt.Sprintf("Invalid label name %q for metric %s.", label, f.name))
+		}
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if _, ok := self.families[f.name]; ok {
+		panic(fmt.Sprintf("Metric %s was already registered.", f.name))
+	}
+
+	f.children = make(map[string]*child)
+
+	self.families[f.name] = f
+}
+
+// Escapes a label value.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Returns the value for a label combination, it's created on first use.
+func (self *family) with(values []string) interface{} {
+	if len(values) != len(self.labels) {
+		panic(fmt.Sprintf("Metric %s expects %d label values, got %d.", self.name, len(self.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if c, ok := self.children[key]; ok {
+		return c.value
+	}
+
+	pairs := make([]string, len(values))
+
+	for i, value := range values {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, self.labels[i], escapeLabel(value))
+	}
+
+	c := &child{labels: strings.Join(pairs, ","), value: self.create()}
+
+	self.children[key] = c
+
+	return c.value
+}
+
+// Formats a sample value.
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Writes a sample line.
+func writeSample(w *bufio.Writer, name string, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+// Writes all the metrics in the text exposition format, sorted by name.
+func (self *Registry) Write(out io.Writer) error {
+	self.mu.Lock()
+
+	names := make([]string, 0, len(self.families))
+
+	for name := range self.families {
+		names = append(names, name)
+	}
+
+	families := make([]*family, 0, len(names))
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		families = append(families, self.families[name])
+	}
+
+	self.mu.Unlock()
+
+	w := bufio.NewWriter(out)
+
+	for _, f := range families {
+		f.mu.Lock()
+
+		children := make([]*child, 0, len(f.children))
+
+		for _, c := range f.children {
+			children = append(children, c)
+		}
+
+		f.mu.Unlock()
+
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].labels < children[j].labels
+		})
+
+		help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help)
+
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+		for _, c := range children {
+			f.write(w, f.name, c.labels, c.value)
+		}
+	}
+
+	return w.Flush()
+}
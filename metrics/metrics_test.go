@@ -0,0 +1,158 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Returns the exposition output of a registry.
+func output(t *testing.T, registry *Registry) string {
+	buf := bytes.NewBuffer(nil)
+	if err := registry.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name         string
+		buckets      []float64
+		observations []float64
+		want         string
+	}{
+		{
+			"cumulative",
+			[]float64{1, 2, 5},
+			[]float64{0.5, 1, 1.5, 3, 10},
+			"h_bucket{le=\"1\"} 2\nh_bucket{le=\"2\"} 3\nh_bucket{le=\"5\"} 4\nh_bucket{le=\"+Inf\"} 5\nh_sum 16\nh_count 5\n",
+		},
+		{
+			"unsorted buckets",
+			[]float64{5, 1},
+			[]float64{2},
+			"h_bucket{le=\"1\"} 0\nh_bucket{le=\"5\"} 1\nh_bucket{le=\"+Inf\"} 1\nh_sum 2\nh_count 1\n",
+		},
+		{
+			"above every bucket",
+			[]float64{0.25},
+			[]float64{1, 2},
+			"h_bucket{le=\"0.25\"} 0\nh_bucket{le=\"+Inf\"} 2\nh_sum 3\nh_count 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		registry := NewRegistry()
+		histogram := registry.NewHistogram("h", "Help.", test.buckets)
+		for _, value := range test.observations {
+			histogram.Observe(value)
+		}
+
+		want := "# HELP h Help.\n# TYPE h histogram\n" + test.want
+
+		if got := output(t, registry); got != want {
+			t.Errorf("%s: got\n%s\nexpecting\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestHistogramLabels(t *testing.T) {
+	registry := NewRegistry()
+
+	registry.NewHistogram("h", "Help.", []float64{1}, "method").With("GET").Observe(0.5)
+
+	want := "h_bucket{method=\"GET\",le=\"1\"} 1\nh_bucket{method=\"GET\",le=\"+Inf\"} 1\nh_sum{method=\"GET\"} 0.5\nh_count{method=\"GET\"} 1\n"
+
+	if got := output(t, registry); strings.HasSuffix(got, want) == false {
+		t.Fatalf("Got\n%s\nexpecting\n%s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	tests := []struct {
+		help  string
+		value string
+		want  string
+	}{
+		{"Plain.", "plain", "# HELP c Plain.\n# TYPE c counter\nc{path=\"plain\"} 1\n"},
+		{"Back\\slash.", "a\\b", "# HELP c Back\\\\slash.\n# TYPE c counter\nc{path=\"a\\\\b\"} 1\n"},
+		{"Two\nlines.", "a\nb", "# HELP c Two\\nlines.\n# TYPE c counter\nc{path=\"a\\nb\"} 1\n"},
+		{"\"Quoted\".", "\"q\"", "# HELP c \"Quoted\".\n# TYPE c counter\nc{path=\"\\\"q\\\"\"} 1\n"},
+	}
+
+	for _, test := range tests {
+		registry := NewRegistry()
+		registry.NewCounter("c", test.help, "path").With(test.value).Inc()
+
+		if got := output(t, registry); got != test.want {
+			t.Errorf("Got %q, expecting %q.", got, test.want)
+		}
+	}
+}
+
+func TestSortedOutput(t *testing.T) {
+	registry := NewRegistry()
+
+	gauge := registry.NewGauge("b", "B.", "k")
+	gauge.With("y").Set(2)
+	gauge.With("x").Set(1)
+
+	registry.NewCounter("a", "A.").Add(1.5)
+
+	want := "# HELP a A.\n# TYPE a counter\na 1.5\n# HELP b B.\n# TYPE b gauge\nb{k=\"x\"} 1\nb{k=\"y\"} 2\n"
+
+	if got := output(t, registry); got != want {
+		t.Fatalf("Got %q, expecting %q.", got, want)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(*Registry)
+	}{
+		{"duplicate", func(r *Registry) { r.NewCounter("c", ""); r.NewGauge("c", "") }},
+		{"invalid name", func(r *Registry) { r.NewCounter("0c", "") }},
+		{"invalid label", func(r *Registry) { r.NewCounter("c", "", "a-b") }},
+		{"reserved label", func(r *Registry) { r.NewHistogram("h", "", nil, "le") }},
+		{"label count", func(r *Registry) { r.NewCounter("c", "", "a").With("x", "y") }},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expecting a panic.", test.name)
+				}
+			}()
+			test.register(NewRegistry())
+		}()
+	}
+}
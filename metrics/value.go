@@ -0,0 +1,193 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sync/atomic"
+)
+
+// A float64 that can be changed atomically.
+type atomicFloat struct {
+	bits uint64
+}
+
+func (self *atomicFloat) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&self.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&self.bits, old, next) {
+			return
+		}
+	}
+}
+
+func (self *atomicFloat) set(value float64) {
+	atomic.StoreUint64(&self.bits, math.Float64bits(value))
+}
+
+func (self *atomicFloat) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&self.bits))
+}
+
+// A value that only goes up, like the number of requests served.
+type Counter struct {
+	family *family
+}
+
+// The value of a Counter for a label combination.
+type CounterValue struct {
+	value atomicFloat
+}
+
+// Registers a new counter with the given label names.
+func (self *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	f := &family{name: name, help: help, kind: "counter", labels: labels}
+
+	f.create = func() interface{} {
+		return &CounterValue{}
+	}
+
+	f.write = func(w *bufio.Writer, name string, labels string, value interface{}) {
+		writeSample(w, name, labels, value.(*CounterValue).value.get())
+	}
+
+	self.register(f)
+
+	return &Counter{f}
+}
+
+// Registers a new counter in the Default registry.
+func NewCounter(name string, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// Returns the value for the given label values.
+func (self *Counter) With(values ...string) *CounterValue {
+	return self.family.with(values).(*CounterValue)
+}
+
+// Adds one to a counter without labels.
+func (self *Counter) Inc() {
+	self.With().Inc()
+}
+
+// Adds to a counter without labels.
+func (self *Counter) Add(delta float64) {
+	self.With().Add(delta)
+}
+
+// Adds one.
+func (self *CounterValue) Inc() {
+	self.value.add(1)
+}
+
+// Adds delta, it must not be negative.
+func (self *CounterValue) Add(delta float64) {
+	if delta < 0 {
+		panic("Counters can't decrease.")
+	}
+	self.value.add(delta)
+}
+
+// A value that goes up and down, like the number of open connections.
+type Gauge struct {
+	family *family
+}
+
+// The value of a Gauge for a label combination.
+type GaugeValue struct {
+	value atomicFloat
+}
+
+// Registers a new gauge with the given label names.
+func (self *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	f := &family{name: name, help: help, kind: "gauge", labels: labels}
+
+	f.create = func() interface{} {
+		return &GaugeValue{}
+	}
+
+	f.write = func(w *bufio.Writer, name string, labels string, value interface{}) {
+		writeSample(w, name, labels, value.(*GaugeValue).value.get())
+	}
+
+	self.register(f)
+
+	return &Gauge{f}
+}
+
+// Registers a new gauge in the Default registry.
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	return Default.NewGauge(name, help, labels...)
+}
+
+// Returns the value for the given label values.
+func (self *Gauge) With(values ...string) *GaugeValue {
+	return self.family.with(values).(*GaugeValue)
+}
+
+// Sets a gauge without labels.
+func (self *Gauge) Set(value float64) {
+	self.With().Set(value)
+}
+
+// Adds one to a gauge without labels.
+func (self *Gauge) Inc() {
+	self.With().Inc()
+}
+
+// Subtracts one from a gauge without labels.
+func (self *Gauge) Dec() {
+	self.With().Dec()
+}
+
+// Adds to a gauge without labels.
+func (self *Gauge) Add(delta float64) {
+	self.With().Add(delta)
+}
+
+// Sets the value.
+func (self *GaugeValue) Set(value float64) {
+	self.value.set(value)
+}
+
+// Adds one.
+func (self *GaugeValue) Inc() {
+	self.value.add(1)
+}
+
+// Subtracts one.
+func (self *GaugeValue) Dec() {
+	self.value.add(-1)
+}
+
+// Adds delta, which may be negative.
+func (self *GaugeValue) Add(delta float64) {
+	self.value.add(delta)
+}
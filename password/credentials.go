@@ -0,0 +1,94 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package password
+
+import (
+	"github.com/astrata/tango/logger"
+	"sync"
+)
+
+// A source of user credentials, usually backed by a database.
+type Credentials interface {
+	// Returns the password hash of a user, ok is false if the user does not
+	// exist.
+	PasswordHash(user string) (hash string, ok bool, err error)
+}
+
+// Credentials that can store a new hash for a user, Verifier() uses it to
+// upgrade hashes that NeedsRehash() reports.
+type Rehasher interface {
+	SetPasswordHash(user string, hash string) error
+}
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// Spends about the same time as checking a real password, so unknown users
+// can't be told apart by timing.
+func verifyDummy(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = Hash("tango")
+	})
+	Verify(password, dummyHash)
+}
+
+// Returns a function that checks a user name and password against the
+// given credentials, it can be used with tango.BasicAuth().
+func Verifier(credentials Credentials) func(user string, password string) bool {
+	return func(user string, password string) bool {
+		hash, ok, err := credentials.PasswordHash(user)
+
+		if err != nil {
+			logger.Error("password: could not read credentials.", "user", user, "error", err)
+			return false
+		}
+
+		if ok == false {
+			verifyDummy(password)
+			return false
+		}
+
+		valid, err := Verify(password, hash)
+
+		if err != nil || valid == false {
+			return false
+		}
+
+		if rehasher, ok := credentials.(Rehasher); ok && NeedsRehash(hash) {
+			if hash, err = Hash(password); err == nil {
+				err = rehasher.SetPasswordHash(user, hash)
+			}
+			if err != nil {
+				logger.Error("password: could not rehash password.", "user", user, "error", err)
+			}
+		}
+
+		return true
+	}
+}
@@ -0,0 +1,237 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+/*
+  This package hashes and verifies passwords with argon2id or bcrypt.
+
+  Hashes are self-describing strings, so the algorithm and costs can be
+  changed at any time: Verify() accepts any hash and NeedsRehash() tells
+  when a hash should be replaced.
+*/
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// Hashing algorithms.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// Returned by Verify() when the hash can't be read.
+var ErrUnknownHash = errors.New("Unknown password hash format.")
+
+// Returned when argon2id costs are out of range.
+var ErrInvalidParams = errors.New("Invalid argon2id parameters.")
+
+// Largest argon2id costs accepted, so a crafted hash can't exhaust the
+// server's memory or CPU.
+var (
+	// KiB.
+	MaxArgon2Memory uint32 = 1024 * 1024
+	MaxArgon2Time   uint32 = 32
+)
+
+// Hashing parameters.
+type Params struct {
+	// Argon2id or Bcrypt.
+	Algorithm string
+
+	// bcrypt cost (4-31).
+	BcryptCost int
+
+	// argon2id number of passes.
+	Time uint32
+	// argon2id memory, in KiB.
+	Memory uint32
+	// argon2id parallelism.
+	Threads uint8
+	// argon2id salt and key sizes, in bytes.
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// Parameters used by Hash(), Verify() and NeedsRehash(). Raise them as
+// hardware gets faster, existing hashes keep working and NeedsRehash()
+// reports them.
+var Default = Params{
+	Algorithm:  Argon2id,
+	BcryptCost: 12,
+	Time:       3,
+	Memory:     64 * 1024,
+	Threads:    2,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+var b64 = base64.RawStdEncoding
+
+// Hashes a password with the default parameters.
+func Hash(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// Checks a password against a hash created with any supported algorithm.
+func Verify(password string, hash string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == nil {
+			return true, nil
+		}
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return false, err
+	}
+	return false, ErrUnknownHash
+}
+
+// Returns true if the hash was not created with the default parameters.
+func NeedsRehash(hash string) bool {
+	return Default.NeedsRehash(hash)
+}
+
+// Hashes a password.
+func (self Params) Hash(password string) (string, error) {
+	switch self.Algorithm {
+	case Argon2id:
+		if err := checkArgon2(self); err != nil {
+			return "", err
+		}
+		salt := make([]byte, self.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, self.Time, self.Memory, self.Threads, self.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, self.Memory, self.Time, self.Threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), self.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+	return "", fmt.Errorf("Unknown password algorithm %q.", self.Algorithm)
+}
+
+// Returns true if the hash was created with another algorithm or other
+// costs.
+func (self Params) NeedsRehash(hash string) bool {
+	switch self.Algorithm {
+	case Argon2id:
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return true
+		}
+		return params.Time != self.Time || params.Memory != self.Memory || params.Threads != self.Threads ||
+			uint32(len(salt)) != self.SaltLength || uint32(len(key)) != self.KeyLength
+	case Bcrypt:
+		if isBcrypt(hash) == false {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != self.BcryptCost
+	}
+	return true
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Checks argon2id costs, argon2.IDKey() panics with zero passes or threads.
+func checkArgon2(params Params) error {
+	switch {
+	case params.Time < 1 || params.Time > MaxArgon2Time:
+		return ErrInvalidParams
+	case params.Threads < 1:
+		return ErrInvalidParams
+	case params.Memory < 8*uint32(params.Threads) || params.Memory > MaxArgon2Memory:
+		return ErrInvalidParams
+	case params.KeyLength < 1:
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+// Reads $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func decodeArgon2(hash string) (Params, []byte, []byte, error) {
+	params := Params{Algorithm: Argon2id}
+
+	chunks := strings.Split(hash, "$")
+
+	if len(chunks) != 6 || chunks[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(chunks[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	if _, err := fmt.Sscanf(chunks[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := b64.DecodeString(chunks[4])
+
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	key, err := b64.DecodeString(chunks[5])
+
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	if err := checkArgon2(params); err != nil {
+		return params, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
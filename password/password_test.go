@@ -0,0 +1,164 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+// Cheap parameters, so tests run fast.
+var (
+	testArgon2 = Params{Algorithm: Argon2id, Time: 1, Memory: 64, Threads: 1, SaltLength: 16, KeyLength: 32}
+	testBcrypt = Params{Algorithm: Bcrypt, BcryptCost: 4}
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, params := range []Params{testArgon2, testBcrypt} {
+		hash, err := params.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: %v", params.Algorithm, err)
+		}
+
+		tests := []struct {
+			password string
+			want     bool
+		}{
+			{"correct horse", true},
+			{"correct horse ", false},
+			{"Correct horse", false},
+			{"", false},
+		}
+
+		for _, test := range tests {
+			ok, err := Verify(test.password, hash)
+			if err != nil || ok != test.want {
+				t.Errorf("%s: Verify(%q) = %v, %v; expecting %v.", params.Algorithm, test.password, ok, err, test.want)
+			}
+		}
+	}
+}
+
+func TestSaltedHashes(t *testing.T) {
+	a, _ := testArgon2.Hash("password")
+	b, _ := testArgon2.Hash("password")
+
+	if a == b {
+		t.Fatalf("Expecting different salts.")
+	}
+}
+
+func TestMalformedHashes(t *testing.T) {
+	valid, err := testArgon2.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := strings.Split(valid, "$")
+
+	tests := []struct {
+		name string
+		hash string
+		err  error
+	}{
+		{"empty", "", ErrUnknownHash},
+		{"plain text", "password", ErrUnknownHash},
+		{"other algorithm", "$argon2i$v=19$m=64,t=1,p=1$" + chunks[4] + "$" + chunks[5], ErrUnknownHash},
+		{"missing key", strings.Join(chunks[:5], "$"), ErrUnknownHash},
+		{"empty key", strings.Join(chunks[:5], "$") + "$", ErrUnknownHash},
+		{"bad version", "$argon2id$v=16$" + strings.Join(chunks[3:], "$"), ErrUnknownHash},
+		{"bad params", "$argon2id$v=19$m=x,t=1,p=1$" + chunks[4] + "$" + chunks[5], ErrUnknownHash},
+		{"bad salt", "$argon2id$v=19$" + chunks[3] + "$!!!$" + chunks[5], ErrUnknownHash},
+		{"zero passes", "$argon2id$v=19$m=64,t=0,p=1$" + chunks[4] + "$" + chunks[5], ErrInvalidParams},
+		{"too many passes", "$argon2id$v=19$m=64,t=1000,p=1$" + chunks[4] + "$" + chunks[5], ErrInvalidParams},
+		{"zero threads", "$argon2id$v=19$m=64,t=1,p=0$" + chunks[4] + "$" + chunks[5], ErrInvalidParams},
+		{"too little memory", "$argon2id$v=19$m=8,t=1,p=2$" + chunks[4] + "$" + chunks[5], ErrInvalidParams},
+		{"too much memory", "$argon2id$v=19$m=4194304,t=1,p=1$" + chunks[4] + "$" + chunks[5], ErrInvalidParams},
+	}
+
+	for _, test := range tests {
+		ok, err := Verify("password", test.hash)
+		if ok == true || err != test.err {
+			t.Errorf("%s: got %v, %v; expecting %v.", test.name, ok, err, test.err)
+		}
+	}
+
+	if _, err := Verify("password", "$2a$04$tooshort"); err == nil {
+		t.Errorf("Expecting an error for a truncated bcrypt hash.")
+	}
+}
+
+func TestHashParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"unknown algorithm", Params{Algorithm: "md5"}},
+		{"zero passes", Params{Algorithm: Argon2id, Memory: 64, Threads: 1, KeyLength: 32}},
+		{"zero threads", Params{Algorithm: Argon2id, Time: 1, Memory: 64, KeyLength: 32}},
+		{"zero key", Params{Algorithm: Argon2id, Time: 1, Memory: 64, Threads: 1}},
+		{"bcrypt cost", Params{Algorithm: Bcrypt, BcryptCost: 40}},
+	}
+
+	for _, test := range tests {
+		if _, err := test.params.Hash("password"); err == nil {
+			t.Errorf("%s: expecting an error.", test.name)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2Hash, _ := testArgon2.Hash("password")
+	bcryptHash, _ := testBcrypt.Hash("password")
+
+	stronger := testArgon2
+	stronger.Time = 2
+
+	costlier := testBcrypt
+	costlier.BcryptCost = 5
+
+	tests := []struct {
+		name   string
+		params Params
+		hash   string
+		want   bool
+	}{
+		{"same argon2id", testArgon2, argon2Hash, false},
+		{"more passes", stronger, argon2Hash, true},
+		{"argon2id to bcrypt", testBcrypt, argon2Hash, true},
+		{"same bcrypt", testBcrypt, bcryptHash, false},
+		{"higher cost", costlier, bcryptHash, true},
+		{"bcrypt to argon2id", testArgon2, bcryptHash, true},
+		{"malformed", testArgon2, "password", true},
+	}
+
+	for _, test := range tests {
+		if got := test.params.NeedsRehash(test.hash); got != test.want {
+			t.Errorf("%s: got %v, expecting %v.", test.name, got, test.want)
+		}
+	}
+}
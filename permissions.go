@@ -0,0 +1,115 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+// Models can declare the roles their methods require by implementing this
+// interface, e.g.:
+//
+//	func (self *Admin) Permissions() map[string][]string {
+//		return map[string][]string{
+//			"*":      {"admin"},           // Any method.
+//			"Delete": {"admin", "editor"}, // Any of these roles.
+//			"Index":  {},                  // Anyone.
+//		}
+//	}
+//
+// A method name takes precedence over "*". Requests without an
+// authenticated user get a 401 and users without any of the roles get a 403.
+type Permissioned interface {
+	Permissions() map[string][]string
+}
+
+// Returns the roles of the user of the current request.
+type RoleResolver func(*Context) []string
+
+// Sets the function that returns the roles of the current user. By default
+// roles are read from the "roles" claim of a JWT (see JWTAuth()).
+func (s *Server) SetRoleResolver(fn RoleResolver) {
+	s.roles = fn
+}
+
+// Reads roles from the "roles" claim of a JWT.
+func claimRoles(context *Context) []string {
+	roles := []string{}
+
+	switch value := context.Claims()["roles"].(type) {
+	case []interface{}:
+		for _, role := range value {
+			if name, ok := role.(string); ok {
+				roles = append(roles, name)
+			}
+		}
+	case []string:
+		roles = value
+	case string:
+		roles = append(roles, value)
+	}
+
+	return roles
+}
+
+// Checks the permissions a model declares for a method, returns 0 if the
+// request may continue or the HTTP status to stop it with.
+func (server *Server) authorize(context *Context, model interface{}, method string) int {
+	permissioned, ok := model.(Permissioned)
+
+	if ok == false {
+		return 0
+	}
+
+	permissions := permissioned.Permissions()
+
+	required, ok := permissions[method]
+
+	if ok == false {
+		required = permissions["*"]
+	}
+
+	if len(required) == 0 {
+		return 0
+	}
+
+	if context.User() == "" {
+		return 401
+	}
+
+	resolve := server.roles
+
+	if resolve == nil {
+		resolve = claimRoles
+	}
+
+	for _, role := range resolve(context) {
+		for _, name := range required {
+			if role == name {
+				return 0
+			}
+		}
+	}
+
+	return 403
+}
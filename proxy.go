@@ -0,0 +1,286 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Parses a list of proxy addresses, either CIDRs ("10.0.0.0/8"), single IPs
+// or "unix" for peers on a UNIX socket.
+func parseProxies(list []string) ([]*net.IPNet, bool, error) {
+	nets := []*net.IPNet{}
+	unix := false
+
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+
+		if item == "unix" {
+			unix = true
+			continue
+		}
+
+		if strings.Contains(item, "/") == false {
+			if strings.Contains(item, ":") {
+				item = item + "/128"
+			} else {
+				item = item + "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(item)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		nets = append(nets, network)
+	}
+
+	return nets, unix, nil
+}
+
+// Sets the proxies whose X-Forwarded-* and Forwarded headers are trusted,
+// as CIDRs ("10.0.0.0/8"), single IPs or "unix" for peers on a UNIX socket.
+// By default no proxy is trusted and the headers are ignored.
+func (s *Server) SetTrustedProxies(proxies ...string) error {
+	nets, unix, err := parseProxies(proxies)
+
+	if err != nil {
+		return err
+	}
+
+	s.proxies = nets
+	s.proxyUnix = unix
+
+	return nil
+}
+
+// Reads the proxy/trusted setting.
+func (s *Server) trustedProxiesSettings() {
+	if err := s.SetTrustedProxies(config.Strings("proxy/trusted")...); err != nil {
+		logger.Warn("Ignoring proxy/trusted.", "error", err)
+	}
+}
+
+// Returns true if the given address belongs to a trusted proxy.
+func (s *Server) trusted(addr string) bool {
+	if addr == "" || addr == "@" {
+		return s.proxyUnix
+	}
+
+	ip := net.ParseIP(addr)
+
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range s.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the host part of an address, with or without a port.
+func hostOf(addr string) string {
+	addr = strings.TrimSpace(addr)
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+// Parses the elements of RFC 7239 Forwarded headers into lists of
+// parameters, from the client side to the server side.
+func forwardedElements(values []string) []map[string]string {
+	elements := []map[string]string{}
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			params := map[string]string{}
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 {
+					params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+				}
+			}
+			elements = append(elements, params)
+		}
+	}
+
+	return elements
+}
+
+// Returns the addresses a request went through, from the client side to the
+// server side, as reported by the proxies.
+func (context *Context) forwardedFor() []string {
+	hops := []string{}
+
+	if values := context.Request.Header["Forwarded"]; len(values) > 0 {
+		for _, element := range forwardedElements(values) {
+			hops = append(hops, hostOf(element["for"]))
+		}
+		return hops
+	}
+
+	for _, value := range context.Request.Header["X-Forwarded-For"] {
+		for _, addr := range strings.Split(value, ",") {
+			hops = append(hops, hostOf(addr))
+		}
+	}
+
+	return hops
+}
+
+// Returns true if the request comes straight from a trusted proxy.
+func (context *Context) viaProxy() bool {
+	return context.Server != nil && context.Server.trusted(hostOf(context.Request.RemoteAddr))
+}
+
+// Returns how many of the forwarded addresses, counting from the server
+// side, were added by trusted proxies. The last one of them is the client.
+func (context *Context) trustedHops(hops []string) int {
+	n := 0
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		// Obfuscated or unknown addresses can't be followed.
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		n++
+		if context.Server.trusted(hops[i]) == false {
+			break
+		}
+	}
+
+	return n
+}
+
+// Returns the IP of the client. If the request comes from a trusted proxy
+// the forwarded addresses are walked from the server side and the first one
+// that is not a trusted proxy is the client.
+func (context *Context) ClientIP() string {
+	if context.clientIP != "" {
+		return context.clientIP
+	}
+
+	ip := hostOf(context.Request.RemoteAddr)
+
+	if context.viaProxy() == true {
+		hops := context.forwardedFor()
+		if n := context.trustedHops(hops); n > 0 {
+			ip = hops[len(hops)-n]
+		}
+	}
+
+	context.clientIP = ip
+
+	return ip
+}
+
+// Returns a forwarded parameter as set by the trusted proxy nearest to the
+// client. Values to the left of it were sent by the client and are ignored.
+func (context *Context) forwarded(param string, header string) string {
+	hops := context.forwardedFor()
+
+	// The proxy we're talking to is trusted even if it didn't add an address.
+	n := context.trustedHops(hops)
+	if n == 0 {
+		n = 1
+	}
+
+	if values := context.Request.Header["Forwarded"]; len(values) > 0 {
+		elements := forwardedElements(values)
+		start := len(elements) - n
+		if start < 0 {
+			start = 0
+		}
+		for _, element := range elements[start:] {
+			if value := element[param]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	values := []string{}
+
+	for _, value := range context.Request.Header[http.CanonicalHeaderKey(header)] {
+		for _, item := range strings.Split(value, ",") {
+			values = append(values, strings.TrimSpace(item))
+		}
+	}
+
+	start := len(values) - n
+	if start < 0 {
+		start = 0
+	}
+
+	for _, value := range values[start:] {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// Returns the scheme ("http" or "https") the client used.
+func (context *Context) Scheme() string {
+	if context.viaProxy() == true {
+		proto := strings.ToLower(context.forwarded("proto", "X-Forwarded-Proto"))
+		if proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+
+	if context.Request.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+// Returns the host the client asked for.
+func (context *Context) Host() string {
+	if context.viaProxy() == true {
+		if host := context.forwarded("host", "X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+
+	return context.Request.Host
+}
@@ -0,0 +1,207 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"fmt"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/astrata/tango/ratelimit"
+	"github.com/gosexy/to"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	rateLimitStore ratelimit.Store
+	rateLimitOnce  sync.Once
+)
+
+// Default header for RateLimitByAPIKey.
+const defaultAPIKeyHeader = "X-API-Key"
+
+// Returns the key a request is counted under.
+type KeyFunc func(*Context) string
+
+// Sets the storage backend for the limiters configured in settings.yaml, it
+// must be called before the server starts. Counters are kept in memory by
+// default.
+func SetRateLimitStore(store ratelimit.Store) {
+	rateLimitStore = store
+}
+
+// Counts requests by client IP, see Context.ClientIP().
+func RateLimitByIP(context *Context) string {
+	return "ip:" + context.ClientIP()
+}
+
+// Counts requests by the user verified by an authentication filter (see
+// Context.User()), anonymous requests are counted by IP. The rate limit must
+// be added after the authentication filter.
+func RateLimitByUser(context *Context) string {
+	user := context.User()
+
+	if user == "" {
+		return RateLimitByIP(context)
+	}
+
+	return "user:" + user
+}
+
+// Counts requests that send an API key in the given header (X-API-Key by
+// default) or in the api_key parameter. Requests are counted under the
+// identity the authentication filter verified for the key (see
+// Context.User()), so the rate limit must be added after it. Other requests
+// are counted by IP.
+func RateLimitByAPIKey(header string) KeyFunc {
+	if header == "" {
+		header = defaultAPIKeyHeader
+	}
+	return func(context *Context) string {
+		key := context.Request.Header.Get(header)
+
+		if key == "" {
+			key = context.Params.Get("api_key")
+		}
+
+		// The key itself is not trusted, clients could send a new one on
+		// every request to get a fresh counter.
+		if key == "" || context.User() == "" {
+			return RateLimitByIP(context)
+		}
+
+		return "key:" + context.User()
+	}
+}
+
+// Returns a Filter that rejects requests over the limit with 429 Too Many
+// Requests. The RateLimit-* headers are sent on every response.
+func RateLimit(limiter ratelimit.Limiter, key KeyFunc) Filter {
+	if key == nil {
+		key = RateLimitByIP
+	}
+	return func(context *Context) int {
+		result, err := limiter.Allow(key(context))
+
+		if err != nil {
+			// The limiter backend being down should not take the site with it.
+			context.Log().Error("Rate limiter failed.", "error", err)
+			return 0
+		}
+
+		context.SetHeader("RateLimit-Policy", limiter.Policy())
+		context.SetHeader("RateLimit-Limit", fmt.Sprintf("%d", result.Limit))
+		context.SetHeader("RateLimit-Remaining", fmt.Sprintf("%d", result.Remaining))
+		context.SetHeader("RateLimit-Reset", fmt.Sprintf("%d", seconds(result.Reset)))
+
+		if result.Allowed == false {
+			context.SetHeader("Retry-After", fmt.Sprintf("%d", seconds(result.RetryAfter)))
+			return 429
+		}
+
+		return 0
+	}
+}
+
+// Rounds up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// Starts the garbage collector of the rate limit store.
+func setupRateLimits() {
+	if rateLimitStore == nil {
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	go func() {
+		for _ = range time.Tick(time.Minute) {
+			if err := rateLimitStore.GC(); err != nil {
+				logger.Error("Rate limit GC failed.", "error", err)
+			}
+		}
+	}()
+}
+
+// Adds the limiters listed under ratelimit/routes in settings.yaml. Limits
+// keyed by user or API key run after the other filters, once authentication
+// filters had a chance to verify the client.
+func (s *Server) rateLimitRoutes() {
+	for _, item := range to.List(config.Get("ratelimit/routes")) {
+		rule := to.Map(item)
+
+		path := to.String(rule["path"])
+		limit := int(to.Int64(rule["limit"]))
+		window := time.Duration(to.Int64(rule["window"])) * time.Second
+
+		if limit <= 0 || window <= 0 {
+			logger.Warn("Ignoring rate limit, limit and window are required.", "path", path)
+			continue
+		}
+
+		rateLimitOnce.Do(setupRateLimits)
+
+		var limiter ratelimit.Limiter
+
+		switch to.String(rule["algorithm"]) {
+		case "token_bucket":
+			limiter = &ratelimit.TokenBucket{
+				Limit:  limit,
+				Window: window,
+				Burst:  int(to.Int64(rule["burst"])),
+				Prefix: path + ":",
+				Store:  rateLimitStore,
+			}
+		default:
+			limiter = &ratelimit.SlidingWindow{
+				Limit:  limit,
+				Window: window,
+				Prefix: path + ":",
+				Store:  rateLimitStore,
+			}
+		}
+
+		var key KeyFunc
+
+		switch to.String(rule["key"]) {
+		case "user":
+			key = RateLimitByUser
+		case "api_key":
+			key = RateLimitByAPIKey(to.String(rule["header"]))
+		default:
+			key = RateLimitByIP
+		}
+
+		switch to.String(rule["key"]) {
+		case "user", "api_key":
+			s.lateFilter(path, RateLimit(limiter, key))
+		default:
+			s.Filter(path, RateLimit(limiter, key))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// A token bucket: it holds up to Burst tokens and is refilled at Limit tokens
+// per Window, every request takes a token. Bursts are allowed as long as the
+// average rate stays under the limit.
+type TokenBucket struct {
+	Limit  int
+	Window time.Duration
+	// Size of the bucket, defaults to Limit.
+	Burst int
+	// Prefix for keys, for limiters that share a Store.
+	Prefix string
+	Store  Store
+}
+
+// Allocates a new &TokenBucket{} that keeps its counters in memory, expired
+// counters are removed every GCInterval.
+func NewTokenBucket(limit int, window time.Duration, burst int) *TokenBucket {
+	return &TokenBucket{Limit: limit, Window: window, Burst: burst, Store: newCollectedStore()}
+}
+
+func (self *TokenBucket) size() float64 {
+	if self.Burst > 0 {
+		return float64(self.Burst)
+	}
+	return float64(self.Limit)
+}
+
+// Takes a token from the bucket of the given key.
+func (self *TokenBucket) Allow(key string) (Result, error) {
+	result := Result{Limit: int(self.size())}
+
+	size := self.size()
+
+	// Tokens per second.
+	rate := float64(self.Limit) / self.Window.Seconds()
+
+	now := time.Now()
+
+	ttl := time.Duration(size/rate*float64(time.Second)) + time.Second
+
+	err := self.Store.Update(self.Prefix+key, ttl, func(state State) State {
+		tokens := size
+
+		if state.Stamp.IsZero() == false {
+			tokens = math.Min(size, state.Value+now.Sub(state.Stamp).Seconds()*rate)
+		}
+
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+		}
+
+		result.Remaining = int(tokens)
+		result.Reset = time.Duration((size - tokens) / rate * float64(time.Second))
+
+		return State{Value: tokens, Stamp: now}
+	})
+
+	return result, err
+}
+
+// Returns the limit for the RateLimit-Policy header.
+func (self *TokenBucket) Policy() string {
+	return policy(int(self.size()), self.Window)
+}
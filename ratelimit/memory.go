@@ -0,0 +1,103 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// A Store that keeps counters in memory.
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string]memoryEntry
+}
+
+// Allocates a new &MemoryStore{}, GC() must be called periodically to remove
+// expired keys.
+func NewMemoryStore() *MemoryStore {
+	self := &MemoryStore{}
+	self.keys = make(map[string]memoryEntry)
+	return self
+}
+
+// Interval between garbage collections of the stores created by
+// NewTokenBucket() and NewSlidingWindow().
+var GCInterval = time.Minute
+
+// Allocates a new &MemoryStore{} whose expired keys are removed every
+// GCInterval.
+func newCollectedStore() *MemoryStore {
+	self := NewMemoryStore()
+
+	go func() {
+		for _ = range time.Tick(GCInterval) {
+			self.GC()
+		}
+	}()
+
+	return self
+}
+
+// Updates the state of a key.
+func (self *MemoryStore) Update(key string, ttl time.Duration, fn func(State) State) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	now := time.Now()
+
+	entry, ok := self.keys[key]
+
+	if ok == false || now.After(entry.expires) {
+		entry = memoryEntry{}
+	}
+
+	self.keys[key] = memoryEntry{fn(entry.state), now.Add(ttl)}
+
+	return nil
+}
+
+// Removes expired keys.
+func (self *MemoryStore) GC() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	now := time.Now()
+
+	for key, entry := range self.keys {
+		if now.After(entry.expires) {
+			delete(self.keys, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+/*
+  This package implements rate limiters for protecting endpoints from abuse.
+
+  Limiters keep their counters in a Store, the MemoryStore works for a single
+  server while a shared backend can be plugged in for several servers.
+*/
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// The outcome of a request against a limiter.
+type Result struct {
+	// True if the request may proceed.
+	Allowed bool
+	// Number of requests allowed in a window.
+	Limit int
+	// Number of requests left.
+	Remaining int
+	// Time until the limit is fully restored.
+	Reset time.Duration
+	// Time the client should wait before retrying a rejected request.
+	RetryAfter time.Duration
+}
+
+// A rate limiter.
+type Limiter interface {
+	// Counts a request for the given key.
+	Allow(key string) (Result, error)
+	// Returns a description of the limit for the RateLimit-Policy header
+	// (e.g. "100;w=60").
+	Policy() string
+}
+
+// Counters of a key, their meaning depends on the limiter.
+type State struct {
+	Value    float64
+	Previous float64
+	Stamp    time.Time
+}
+
+// A storage backend for limiter counters. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Reads the state of a key (a zero State if there is none), passes it to
+	// fn and saves the state fn returns, all in one atomic step. The key may
+	// be forgotten after ttl.
+	Update(key string, ttl time.Duration, fn func(State) State) error
+	// Removes expired keys.
+	GC() error
+}
+
+func policy(limit int, window time.Duration) string {
+	return fmt.Sprintf("%d;w=%d", limit, int64(window/time.Second))
+}
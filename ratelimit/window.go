@@ -0,0 +1,100 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// A sliding window: at most Limit requests within any Window. The count of
+// the previous fixed window is weighted by how much of it still overlaps the
+// sliding one, so only two counters per key are needed.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+	// Prefix for keys, for limiters that share a Store.
+	Prefix string
+	Store  Store
+}
+
+// Allocates a new &SlidingWindow{} that keeps its counters in memory,
+// expired counters are removed every GCInterval.
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{Limit: limit, Window: window, Store: newCollectedStore()}
+}
+
+// Counts a request for the given key.
+func (self *SlidingWindow) Allow(key string) (Result, error) {
+	result := Result{Limit: self.Limit}
+
+	now := time.Now()
+	start := now.Truncate(self.Window)
+	elapsed := now.Sub(start)
+	limit := float64(self.Limit)
+
+	err := self.Store.Update(self.Prefix+key, 2*self.Window, func(state State) State {
+		var current, previous float64
+
+		switch {
+		case state.Stamp.Equal(start):
+			current, previous = state.Value, state.Previous
+		case state.Stamp.Equal(start.Add(-self.Window)):
+			previous = state.Value
+		}
+
+		weight := 1 - elapsed.Seconds()/self.Window.Seconds()
+
+		count := previous*weight + current
+
+		if count+1 <= limit {
+			current++
+			count++
+			result.Allowed = true
+		} else {
+			// Waiting until the previous window weighs little enough, or
+			// for the next window if this one is already full.
+			result.RetryAfter = self.Window - elapsed
+			if previous > 0 && current+1 <= limit {
+				wait := self.Window.Seconds()*(1-(limit-1-current)/previous) - elapsed.Seconds()
+				result.RetryAfter = time.Duration(wait * float64(time.Second))
+			}
+		}
+
+		result.Remaining = int(math.Max(0, limit-math.Ceil(count)))
+		result.Reset = self.Window - elapsed
+
+		return State{Value: current, Previous: previous, Stamp: start}
+	})
+
+	return result, err
+}
+
+// Returns the limit for the RateLimit-Policy header.
+func (self *SlidingWindow) Policy() string {
+	return policy(self.Limit, self.Window)
+}
@@ -0,0 +1,124 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	gocontext "context"
+	"fmt"
+	"github.com/astrata/tango/logger"
+	"github.com/astrata/tango/token"
+	"io"
+	"net/http"
+)
+
+// Header that carries the request ID, it's read from requests and echoed in
+// responses.
+var RequestIDHeader = "X-Request-ID"
+
+// Longest request ID accepted from a client.
+const maxRequestIDSize = 128
+
+// Key for storing the request ID within a request context.
+type requestIDKey struct{}
+
+// Returns true if an incoming request ID is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDSize {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the ID of the request, generated if the client didn't send one.
+func newRequestID(request *http.Request) string {
+	if id := request.Header.Get(RequestIDHeader); validRequestID(id) == true {
+		return id
+	}
+
+	id, err := token.UUID7()
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return id
+}
+
+// Returns the request ID stored in a context.Context by the server, for code
+// that only gets to see the standard context.
+func RequestIDFrom(ctx gocontext.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Returns the ID of the current request. It's taken from the X-Request-ID
+// header or generated, and is sent back in the response and written in the
+// access log.
+func (context *Context) RequestID() string {
+	return context.requestID
+}
+
+// Returns the framework logger with the request ID, method, path and client
+// address of the current request as fields.
+func (context *Context) Log() logger.Logger {
+	if context.logger == nil {
+		context.logger = logger.Get().With(
+			"request_id", context.requestID,
+			"method", context.Request.Method,
+			"path", context.Request.URL.Path,
+			"remote", context.ClientIP(),
+		)
+	}
+	return context.logger
+}
+
+// Creates a request for calling another service on behalf of the current
+// one, it carries the request ID and is canceled if the client goes away.
+func (context *Context) NewRequest(method string, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(context.Request.Context(), method, url, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set(RequestIDHeader, context.requestID)
+
+	return request, nil
+}
+
+// Sends a plain text error page that shows the request ID.
+func (context *Context) errorPage(status int) {
+	http.Error(context.Writer, fmt.Sprintf("%s\n\nRequest ID: %s", http.StatusText(status), context.requestID), status)
+}
@@ -0,0 +1,85 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Wraps the http.ResponseWriter of a request to know whether the response
+// was already started or the connection taken over.
+type responseWriter struct {
+	http.ResponseWriter
+
+	// True once the status line was sent.
+	started bool
+	// True once the connection was hijacked (e.g. by a WebSocket).
+	hijacked bool
+}
+
+func (self *responseWriter) WriteHeader(status int) {
+	self.started = true
+	self.ResponseWriter.WriteHeader(status)
+}
+
+func (self *responseWriter) Write(p []byte) (int, error) {
+	self.started = true
+	return self.ResponseWriter.Write(p)
+}
+
+// Sends buffered data to the client, see http.Flusher.
+func (self *responseWriter) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		self.started = true
+		flusher.Flush()
+	}
+}
+
+// Takes over the connection, see http.Hijacker.
+func (self *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := self.ResponseWriter.(http.Hijacker)
+
+	if ok == false {
+		return nil, nil, fmt.Errorf("The response writer does not support hijacking.")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+
+	if err == nil {
+		self.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+// Returns the original writer, for http.ResponseController.
+func (self *responseWriter) Unwrap() http.ResponseWriter {
+	return self.ResponseWriter
+}
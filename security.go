@@ -0,0 +1,185 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/astrata/tango/body"
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/token"
+	"github.com/gosexy/to"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Placeholder for the nonce of the current request within a CSP.
+const CSPNonceTag = "{nonce}"
+
+// Largest CSP violation report that is read.
+const maxCSPReportSize = 64 * 1024
+
+// Security headers sent with every response, empty values are not sent.
+type SecurityHeaders struct {
+	// Strict-Transport-Security, e.g. "max-age=31536000; includeSubDomains".
+	HSTS string
+	// Content-Security-Policy. Every {nonce} is replaced with the nonce of
+	// the request, e.g. "script-src 'self' 'nonce-{nonce}'", see
+	// Context.CSPNonce().
+	CSP string
+	// Sends the CSP as Content-Security-Policy-Report-Only, violations are
+	// reported but not blocked.
+	CSPReportOnly bool
+	// Where browsers send violation reports, see CSPReport().
+	CSPReportURI string
+	// X-Frame-Options.
+	FrameOptions string
+	// X-Content-Type-Options.
+	ContentTypeOptions string
+	// Referrer-Policy.
+	ReferrerPolicy string
+	// Permissions-Policy, e.g. "camera=(), microphone=()".
+	PermissionsPolicy string
+}
+
+func init() {
+	// <script nonce="{{csp_nonce}}">...</script>
+	body.TemplateFunc("csp_nonce", func(request *http.Request) interface{} {
+		if context := contextOf(request); context != nil {
+			return context.CSPNonce()
+		}
+		return ""
+	})
+}
+
+// Reads a setting, the default is used if the setting is missing.
+func securitySetting(name string, value string) string {
+	if setting := config.Get("security/headers/" + name); setting != nil {
+		value = to.String(setting)
+	}
+	if value == "off" {
+		return ""
+	}
+	return value
+}
+
+// Returns the headers configured under security/headers in settings.yaml.
+// Unless they're set to "off", X-Frame-Options, X-Content-Type-Options and
+// Referrer-Policy have safe defaults.
+func securitySettings() *SecurityHeaders {
+	return &SecurityHeaders{
+		HSTS:               securitySetting("hsts", ""),
+		CSP:                securitySetting("csp", ""),
+		CSPReportOnly:      to.Bool(config.Get("security/headers/csp_report_only")),
+		CSPReportURI:       securitySetting("csp_report", ""),
+		FrameOptions:       securitySetting("frame_options", "SAMEORIGIN"),
+		ContentTypeOptions: securitySetting("content_type_options", "nosniff"),
+		ReferrerPolicy:     securitySetting("referrer_policy", "strict-origin-when-cross-origin"),
+		PermissionsPolicy:  securitySetting("permissions_policy", ""),
+	}
+}
+
+// Replaces the security headers, nil disables them.
+func (s *Server) SetSecurityHeaders(headers *SecurityHeaders) {
+	s.security = headers
+}
+
+// Returns a nonce for inline scripts and styles allowed by the CSP, it's
+// the same for the whole request.
+func (context *Context) CSPNonce() string {
+	if context.nonce == "" {
+		var err error
+		context.nonce, err = token.URLSafe(16)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+	return context.nonce
+}
+
+// Sets the security headers of a response.
+func (headers *SecurityHeaders) apply(context *Context) {
+	header := context.Writer.Header()
+
+	set := func(name string, value string) {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+
+	set("Strict-Transport-Security", headers.HSTS)
+	set("X-Frame-Options", headers.FrameOptions)
+	set("X-Content-Type-Options", headers.ContentTypeOptions)
+	set("Referrer-Policy", headers.ReferrerPolicy)
+	set("Permissions-Policy", headers.PermissionsPolicy)
+
+	if headers.CSP != "" {
+		csp := headers.CSP
+
+		if strings.Contains(csp, CSPNonceTag) {
+			csp = strings.Replace(csp, CSPNonceTag, context.CSPNonce(), -1)
+		}
+
+		if headers.CSPReportURI != "" {
+			csp = strings.TrimRight(csp, "; ") + "; report-uri " + headers.CSPReportURI
+		}
+
+		if headers.CSPReportOnly == true {
+			header.Set("Content-Security-Policy-Report-Only", csp)
+		} else {
+			header.Set("Content-Security-Policy", csp)
+		}
+	}
+}
+
+// Returns a Filter that logs the CSP violation reports browsers POST to it,
+// both the report-uri (application/csp-report) and the Reporting API
+// (application/reports+json) formats.
+func CSPReport() Filter {
+	return func(context *Context) int {
+		if context.Request.Method != "POST" {
+			return 405
+		}
+
+		data, err := ioutil.ReadAll(http.MaxBytesReader(context.Writer, context.Request.Body, maxCSPReportSize))
+
+		if err != nil {
+			return 413
+		}
+
+		report := bytes.NewBuffer(nil)
+
+		if err := json.Compact(report, data); err != nil {
+			return 400
+		}
+
+		context.Log().Warn("CSP violation.", "report", report.String())
+
+		return 204
+	}
+}
@@ -32,22 +32,47 @@ import (
 	"github.com/astrata/tango/body"
 	"github.com/astrata/tango/clf"
 	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
 	"github.com/gosexy/to"
-	"log"
 	"math"
 	"net"
 	"net/http"
 	"net/http/fcgi"
 	"os"
+	"os/signal"
 	"reflect"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
+// A Filter is called before a request is routed to a model. Returning a
+// non-zero HTTP status stops the request with that status.
+type Filter func(*Context) int
+
+// A Filter that only applies to a path and its subpaths.
+type routeFilter struct {
+	path string
+	fn   Filter
+}
+
 // Server structure, provides Context for every request.
 type Server struct {
 	serveMux *http.ServeMux
 	routes   map[string][]interface{}
+	filters  []routeFilter
+
+	// Filters that run after all the others.
+	lateFilters []routeFilter
+
+	roles RoleResolver
+
+	security *SecurityHeaders
+
+	proxies   []*net.IPNet
+	proxyUnix bool
+
+	accessLog *clf.Logger
 
 	listener net.Listener
 
@@ -61,6 +86,29 @@ func NewServer() *Server {
 	s.serveMux = http.NewServeMux()
 	s.routes = make(map[string][]interface{})
 
+	s.trustedProxiesSettings()
+
+	s.accessLog = accessLogSettings()
+
+	s.metricsSettings()
+
+	s.security = securitySettings()
+
+	if s.security.CSPReportURI != "" {
+		s.Filter(s.security.CSPReportURI, CSPReport())
+	}
+
+	// Preflights are answered before anything else.
+	if cors := corsSettings(); cors != nil {
+		s.Filter("/", cors)
+	}
+
+	s.rateLimitRoutes()
+
+	if to.Bool(config.Get("csrf/enabled")) == true {
+		s.Filter("/", CSRF(config.Strings("csrf/exempt")...))
+	}
+
 	return s
 }
 
@@ -82,13 +130,32 @@ func (server *Server) Run() error {
 	server.listener, err = net.Listen(domain, addr)
 
 	if err != nil {
-		log.Fatalf("Failed to bind on %s: %s", addr, err.Error())
+		logger.Fatal("Failed to bind.", "addr", addr, "error", err)
 	}
 
 	defer server.listener.Close()
 
-	log.Printf("%s is ready to dance.\n", server.listener.Addr())
-	log.Printf("Stop server with ^C.\n")
+	// Stopping on ^C or SIGTERM closes the listener, so the access log can
+	// be flushed below.
+	signals := make(chan os.Signal, 1)
+	stop := make(chan bool)
+
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(signals)
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-signals:
+			logger.Info("Stopping server.")
+			server.listener.Close()
+		case <-stop:
+		}
+	}()
+
+	logger.Info(fmt.Sprintf("%s is ready to dance.", server.listener.Addr()))
+	logger.Info("Stop server with ^C.")
 
 	fmt.Fprintf(os.Stderr, "\n")
 
@@ -97,19 +164,33 @@ func (server *Server) Run() error {
 		if err == nil {
 			fcgi.Serve(server.listener, server.serveMux)
 		} else {
-			log.Fatalf("Failed to start FastCGI server.")
+			logger.Fatal("Failed to start FastCGI server.")
 		}
 	default:
 		if err == nil {
 			http.Serve(server.listener, server.serveMux)
 		} else {
-			log.Fatalf("Failed to start HTTP server.")
+			logger.Fatal("Failed to start HTTP server.")
 		}
 	}
 
+	if err = server.Close(); err != nil {
+		logger.Error("Failed to close access log.", "error", err)
+	}
+
 	return nil
 }
 
+// Writes the queued access log lines and closes the access log. Run() calls
+// it when the server stops, apps that serve the Server with their own
+// http.Server must call it on shutdown.
+func (s *Server) Close() error {
+	if s.accessLog == nil {
+		return nil
+	}
+	return s.accessLog.Close()
+}
+
 // Maps a route to an interface{}
 func (s *Server) Connect(path string, fn interface{}) {
 	path = strings.ToLower(path)
@@ -118,6 +199,50 @@ func (s *Server) Connect(path string, fn interface{}) {
 	s.routes[path] = append(s.routes[path], fn)
 }
 
+// Adds a Filter for the given path and its subpaths, filters run in the
+// same order they were added.
+func (s *Server) Filter(path string, fn Filter) {
+	path = strings.ToLower(path)
+	path = fmt.Sprintf("/%s", strings.Trim(path, "/"))
+
+	s.filters = append(s.filters, routeFilter{path, fn})
+}
+
+// Adds a Filter that runs after the ones added with Filter().
+func (s *Server) lateFilter(path string, fn Filter) {
+	path = strings.ToLower(path)
+	path = fmt.Sprintf("/%s", strings.Trim(path, "/"))
+
+	s.lateFilters = append(s.lateFilters, routeFilter{path, fn})
+}
+
+// Returns true if the given path is route or one of its subpaths.
+func underRoute(route string, path string) bool {
+	route = strings.ToLower(strings.TrimRight(route, "/"))
+	path = strings.ToLower(path)
+
+	if route == "" || path == route {
+		return true
+	}
+
+	return strings.HasPrefix(path, route+"/")
+}
+
+// Runs the filters that apply to the current request, returns the status
+// of the first filter that stops it.
+func (server *Server) filter(context *Context) int {
+	for _, filters := range [][]routeFilter{server.filters, server.lateFilters} {
+		for _, f := range filters {
+			if underRoute(f.path, context.Request.URL.Path) == true {
+				if status := f.fn(context); status != 0 {
+					return status
+				}
+			}
+		}
+	}
+	return 0
+}
+
 // Routes a *Context to an interface{}
 func (server *Server) Route(context *Context) {
 	// TODO: Should clean this whole method.
@@ -125,6 +250,7 @@ func (server *Server) Route(context *Context) {
 	var name string
 	var status int
 	var content []byte
+	var streamer body.Streamer
 
 	status = 404
 	content = []byte{}
@@ -138,8 +264,15 @@ func (server *Server) Route(context *Context) {
 	// Default content type
 	context.SetHeader("Content-Type", "text/html; charset=utf8")
 
+	if server.security != nil {
+		server.security.apply(context)
+	}
+
+	// Filters may stop the request before it reaches any model.
+	filtered := server.filter(context)
+
 	// Checking for the first chunk that matches a map.
-	for i := len(chunks); i >= 0; i-- {
+	for i := len(chunks); i >= 0 && filtered == 0; i-- {
 
 		name = fmt.Sprintf("/%s", strings.Join(chunks[0:i], "/"))
 
@@ -180,6 +313,11 @@ func (server *Server) Route(context *Context) {
 
 				method, methodExists = stype.MethodByName(methodName)
 
+				// Permissions() is read by the router, it's not an action.
+				if methodName == "Permissions" {
+					methodExists = false
+				}
+
 				if methodExists == false {
 					method, methodExists = stype.MethodByName("CatchAll")
 					if methodExists == true {
@@ -191,7 +329,15 @@ func (server *Server) Route(context *Context) {
 			// Method methodExists
 			if methodExists {
 
-				//log.Printf("/%s -> %v", path, method)
+				//logger.Debug("Routing.", "path", path, "method", method.Name)
+
+				context.route = name + ":" + method.Name
+
+				// Checking the roles the model requires for this method, a
+				// denied request leaves through the common exit below.
+				if status = server.authorize(context, fn, method.Name); status != 0 {
+					break
+				}
 
 				// Copying context into Model.
 				if reflect.ValueOf(fn).Elem().FieldByName("Context").IsValid() == true {
@@ -206,6 +352,24 @@ func (server *Server) Route(context *Context) {
 					reflect.ValueOf(fn).Elem().FieldByName("Files").Set(reflect.ValueOf(context.Files))
 				}
 
+				// Methods with a *Socket argument are WebSocket endpoints.
+				var socket *Socket
+
+				if acceptsSocket(method.Type) == true {
+					var err error
+					socket, err = context.upgrade()
+					if err != nil {
+						context.Log().Warn("Failed to open WebSocket.", "error", err)
+						// Nothing can be written into a hijacked connection.
+						if context.response.hijacked == true {
+							context.finish(400, 0)
+							return
+						}
+						status = 400
+						break
+					}
+				}
+
 				// Number of arguments this func requires.
 				var argc = method.Type.NumIn()
 
@@ -229,6 +393,14 @@ func (server *Server) Route(context *Context) {
 					currentType := method.Type.In(argn)
 					currentValue := reflect.Zero(currentType)
 
+					// The socket does not take a path chunk.
+					if currentType == socketType {
+						args = append(args, reflect.ValueOf(socket))
+						a++
+						j--
+						continue
+					}
+
 					// Current string value
 					if j < len(chunks) {
 
@@ -302,6 +474,21 @@ func (server *Server) Route(context *Context) {
 					output = method.Func.Call(args)
 				}
 
+				// The connection was taken over by the socket.
+				if socket != nil {
+					socket.Close()
+					context.finish(101, 0)
+					return
+				}
+
+				// Giving the body a chance to look at the request, this happens
+				// before afterExecute() because rendering may use the session.
+				if len(output) > 0 {
+					if preparer, ok := output[0].Interface().(body.Preparer); ok {
+						preparer.Prepare(context.Request)
+					}
+				}
+
 				// Callback after execution.
 				context.afterExecute()
 
@@ -319,9 +506,14 @@ func (server *Server) Route(context *Context) {
 							context.Writer.Header()[k] = v
 						}
 
-						content = value.(body.Body).Get()
-
 						status = value.(body.Body).Status()
+
+						if s, ok := value.(body.Streamer); ok && status == 200 {
+							// The body will write into the response by itself.
+							streamer = s
+						} else {
+							content = value.(body.Body).Get()
+						}
 					case string:
 						context.Writer.Header().Set("Content-type", "text/html; charset=utf8")
 						content = []byte(value.(string))
@@ -348,19 +540,59 @@ func (server *Server) Route(context *Context) {
 		}
 	}
 
+	if filtered != 0 {
+		status = filtered
+	}
+
+	// Denied, missing and filtered requests still save their session and
+	// send their cookies, this does nothing if the model already did it.
+	context.afterExecute()
+
 	size := len(content)
 
-	if status != 200 {
-		http.Error(context.Writer, http.StatusText(status), status)
-	}
+	if streamer != nil {
+		var err error
 
-	context.Writer.Write(content)
+		context.Writer.WriteHeader(status)
 
-	clf.Print(context.Request, status, size)
+		size, err = streamer.Stream(context.Writer)
+
+		if err != nil {
+			context.Log().Warn("Failed to stream.", "error", err)
+		}
+	} else {
+		if status == 204 || status == 304 {
+			// These responses have no body.
+			context.Writer.Header().Del("Content-Type")
+			context.Writer.WriteHeader(status)
+		} else if status != 200 {
+			if size == 0 {
+				// Generic error page.
+				context.errorPage(status)
+			} else {
+				// The body brings its own error document (e.g. body.Problem).
+				context.Writer.WriteHeader(status)
+			}
+		}
+
+		context.Writer.Write(content)
+	}
+
+	context.finish(status, size)
 }
 
 // Interface method for handling HTTP.
 func (server *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	metricInFlight.Inc()
+	defer metricInFlight.Dec()
+
 	context := newContext(server, writer, request)
+
+	defer func() {
+		if err := recover(); err != nil {
+			context.recoverPanic(err)
+		}
+	}()
+
 	server.Route(context)
 }
@@ -0,0 +1,178 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"github.com/astrata/tango/config"
+	"github.com/astrata/tango/logger"
+	"github.com/astrata/tango/session"
+	"github.com/gosexy/to"
+	"sync"
+	"time"
+)
+
+var (
+	sessionStore session.Store
+	sessionOnce  sync.Once
+)
+
+// Default session settings.
+const (
+	defaultSessionName     = "tango_session"
+	defaultSessionPath     = "temp/sessions"
+	defaultSessionLifetime = 3600
+	defaultSessionGC       = 600
+)
+
+// Sets the storage backend for sessions, it must be called before the server
+// starts. If no store is set, the session/store setting chooses between
+// "memory" (default) and "file".
+func SetSessionStore(store session.Store) {
+	sessionStore = store
+}
+
+// Prepares the session store and starts its garbage collector.
+func setupSessions() {
+	if sessionStore == nil {
+		switch to.String(config.Get("session/store")) {
+		case "file":
+			path := to.String(config.Get("session/path"))
+			if path == "" {
+				path = defaultSessionPath
+			}
+			store, err := session.NewFileStore(path)
+			if err != nil {
+				logger.Warn("Could not use the file store for sessions, falling back to memory.", "path", path, "error", err)
+				sessionStore = session.NewMemoryStore()
+			} else {
+				sessionStore = store
+			}
+		default:
+			sessionStore = session.NewMemoryStore()
+		}
+	}
+
+	interval := to.Int64(config.Get("session/gc"))
+
+	if interval == 0 {
+		interval = defaultSessionGC
+	}
+
+	go func() {
+		for _ = range time.Tick(time.Duration(interval) * time.Second) {
+			if err := sessionStore.GC(); err != nil {
+				logger.Error("Session GC failed.", "error", err)
+			}
+		}
+	}()
+}
+
+// Returns the name of the session cookie.
+func sessionName() string {
+	name := to.String(config.Get("session/name"))
+	if name == "" {
+		return defaultSessionName
+	}
+	return name
+}
+
+// Returns the session lifetime.
+func sessionLifetime() time.Duration {
+	seconds := to.Int64(config.Get("session/lifetime"))
+	if seconds == 0 {
+		seconds = defaultSessionLifetime
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Returns the session of the current request, a new one is started if the
+// client does not have a valid session cookie. Changes are saved after the
+// model method returns. If no session can be started, the error is logged
+// and an empty session that is never saved is returned.
+func (context *Context) Session() *session.Session {
+	if context.session != nil {
+		return context.session
+	}
+
+	sessionOnce.Do(setupSessions)
+
+	var err error
+
+	lifetime := sessionLifetime()
+
+	if id, err := context.SecureCookie(sessionName()); err == nil && id != "" {
+		context.session, err = session.Load(sessionStore, id, lifetime)
+		if err != nil {
+			context.Log().Error("Could not load session.", "error", err)
+		}
+	}
+
+	if context.session == nil {
+		context.session, err = session.New(sessionStore, lifetime)
+		if err != nil {
+			context.Log().Error("Could not start session.", "error", err)
+			context.session = session.Unsaved(lifetime)
+		}
+	}
+
+	return context.session
+}
+
+// Saves the session and sends its cookie.
+func (context *Context) saveSession() {
+	sess := context.session
+
+	if sess == nil || sess.Dirty() == false {
+		return
+	}
+
+	err := sess.Save()
+
+	if err != nil {
+		context.Log().Error("Could not save session.", "error", err)
+		return
+	}
+
+	value := sess.Id()
+
+	if sess.Destroyed() == true {
+		value = ""
+	}
+
+	cookie, err := context.SetSecureCookie(sessionName(), value)
+
+	if err != nil {
+		context.Log().Error("Could not set session cookie.", "error", err)
+		return
+	}
+
+	if sess.Destroyed() == true {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(sess.Lifetime() / time.Second)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package session
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// IDs that can be safely used as file names.
+var fileIdExpr = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+type fileEntry struct {
+	Expires time.Time
+	Data    Data
+}
+
+// How old a leftover temporary file must be before GC() removes it, if no
+// session was saved yet.
+const fileTempLifetime = time.Hour
+
+// A Store that keeps every session in its own file.
+type FileStore struct {
+	Path string
+
+	// Longest lifetime given to Set(), in nanoseconds.
+	lifetime int64
+}
+
+// Returns a FileStore that saves sessions into the given directory, it's
+// created if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	err := os.MkdirAll(path, 0700)
+
+	if err != nil {
+		return nil, err
+	}
+
+	self := &FileStore{}
+	self.Path = path
+
+	return self, nil
+}
+
+func (self *FileStore) filename(id string) (string, error) {
+	if fileIdExpr.MatchString(id) == false {
+		return "", fmt.Errorf("Invalid session id.")
+	}
+	return filepath.Join(self.Path, "session_"+id), nil
+}
+
+// Reads the data of a session.
+func (self *FileStore) Get(id string) (Data, error) {
+	filename, err := self.filename(id)
+
+	if err != nil {
+		return nil, nil
+	}
+
+	entry, err := readFileEntry(filename)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if time.Now().After(entry.Expires) {
+		os.Remove(filename)
+		return nil, nil
+	}
+
+	if entry.Data == nil {
+		// gob does not send empty maps.
+		entry.Data = Data{}
+	}
+
+	return entry.Data, nil
+}
+
+// Writes the data of a session.
+func (self *FileStore) Set(id string, data Data, lifetime time.Duration) error {
+	filename, err := self.filename(id)
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		longest := atomic.LoadInt64(&self.lifetime)
+		if int64(lifetime) <= longest || atomic.CompareAndSwapInt64(&self.lifetime, longest, int64(lifetime)) {
+			break
+		}
+	}
+
+	// Writing to a temporary file first so readers never see partial data,
+	// every save gets its own file since a browser may send requests at the
+	// same time.
+	file, err := os.CreateTemp(self.Path, filepath.Base(filename)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	temp := file.Name()
+
+	err = gob.NewEncoder(file).Encode(fileEntry{time.Now().Add(lifetime), data})
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temp, filename)
+	}
+
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	return nil
+}
+
+// Removes a session.
+func (self *FileStore) Delete(id string) error {
+	filename, err := self.filename(id)
+
+	if err != nil {
+		return nil
+	}
+
+	err = os.Remove(filename)
+
+	if err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+
+	return nil
+}
+
+// Removes expired sessions and temporary files left by interrupted saves.
+func (self *FileStore) GC() error {
+	files, err := filepath.Glob(filepath.Join(self.Path, "session_*"))
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	tempLifetime := time.Duration(atomic.LoadInt64(&self.lifetime))
+
+	if tempLifetime == 0 {
+		tempLifetime = fileTempLifetime
+	}
+
+	for _, filename := range files {
+		if filepath.Ext(filename) == ".tmp" {
+			if info, err := os.Stat(filename); err == nil && now.Sub(info.ModTime()) > tempLifetime {
+				os.Remove(filename)
+			}
+			continue
+		}
+		entry, err := readFileEntry(filename)
+		if err != nil || now.After(entry.Expires) {
+			os.Remove(filename)
+		}
+	}
+
+	return nil
+}
+
+func readFileEntry(filename string) (*fileEntry, error) {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	entry := &fileEntry{}
+
+	err = gob.NewDecoder(file).Decode(entry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,108 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	data    Data
+	expires time.Time
+}
+
+// A Store that keeps sessions in memory, they're lost when the server stops.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+// Allocates a new &MemoryStore{}.
+func NewMemoryStore() *MemoryStore {
+	self := &MemoryStore{}
+	self.sessions = make(map[string]memoryEntry)
+	return self
+}
+
+// Returns a copy of the data of a session.
+func (self *MemoryStore) Get(id string) (Data, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	entry, ok := self.sessions[id]
+
+	if ok == false || time.Now().After(entry.expires) {
+		return nil, nil
+	}
+
+	return copyData(entry.data), nil
+}
+
+// Saves a copy of the data of a session.
+func (self *MemoryStore) Set(id string, data Data, lifetime time.Duration) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.sessions[id] = memoryEntry{copyData(data), time.Now().Add(lifetime)}
+
+	return nil
+}
+
+// Removes a session.
+func (self *MemoryStore) Delete(id string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	delete(self.sessions, id)
+
+	return nil
+}
+
+// Removes expired sessions.
+func (self *MemoryStore) GC() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	now := time.Now()
+
+	for id, entry := range self.sessions {
+		if now.After(entry.expires) {
+			delete(self.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func copyData(data Data) Data {
+	result := make(Data, len(data))
+	for k, v := range data {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,244 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+/*
+  This package implements server-side sessions with pluggable storage
+  backends.
+
+  Values are kept in a Data map that stores must be able to persist, the
+  FileStore uses encoding/gob so custom types need to be registered with
+  gob.Register().
+*/
+package session
+
+import (
+	"github.com/astrata/tango/token"
+	"time"
+)
+
+// Session values.
+type Data map[string]interface{}
+
+// A storage backend for sessions. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Returns the data of a session, or nil if it does not exist or has
+	// expired.
+	Get(id string) (Data, error)
+	// Saves the data of a session, it should expire after the given lifetime.
+	Set(id string, data Data, lifetime time.Duration) error
+	// Removes a session.
+	Delete(id string) error
+	// Removes expired sessions.
+	GC() error
+}
+
+// A session, bound to a Store.
+type Session struct {
+	id       string
+	data     Data
+	store    Store
+	lifetime time.Duration
+
+	// Previous ID after Regenerate().
+	oldId string
+
+	isNew     bool
+	changed   bool
+	destroyed bool
+	// Sessions from Unsaved() never reach a store.
+	unsaved bool
+}
+
+// Generates a random session ID.
+func NewId() (string, error) {
+	return token.URLSafe(32)
+}
+
+// Starts a new, empty session.
+func New(store Store, lifetime time.Duration) (*Session, error) {
+	id, err := NewId()
+
+	if err != nil {
+		return nil, err
+	}
+
+	self := &Session{}
+	self.id = id
+	self.data = Data{}
+	self.store = store
+	self.lifetime = lifetime
+	self.isNew = true
+
+	return self, nil
+}
+
+// Returns an empty session that is never saved, for requests that can't get
+// a real session (e.g. the store failed).
+func Unsaved(lifetime time.Duration) *Session {
+	self := &Session{}
+	self.data = Data{}
+	self.lifetime = lifetime
+	self.isNew = true
+	self.unsaved = true
+	return self
+}
+
+// Loads the session with the given ID, a new session is started if it
+// does not exist or has expired.
+func Load(store Store, id string, lifetime time.Duration) (*Session, error) {
+	data, err := store.Get(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return New(store, lifetime)
+	}
+
+	self := &Session{}
+	self.id = id
+	self.data = data
+	self.store = store
+	self.lifetime = lifetime
+
+	return self, nil
+}
+
+// Returns the session ID.
+func (self *Session) Id() string {
+	return self.id
+}
+
+// Returns true if the session was started on this request.
+func (self *Session) IsNew() bool {
+	return self.isNew
+}
+
+// Returns true if the session was destroyed.
+func (self *Session) Destroyed() bool {
+	return self.destroyed
+}
+
+// Returns the session lifetime.
+func (self *Session) Lifetime() time.Duration {
+	return self.lifetime
+}
+
+// Returns a session value.
+func (self *Session) Get(name string) interface{} {
+	return self.data[name]
+}
+
+// Sets a session value.
+func (self *Session) Set(name string, value interface{}) {
+	self.data[name] = value
+	self.changed = true
+}
+
+// Removes a session value.
+func (self *Session) Delete(name string) {
+	if _, ok := self.data[name]; ok {
+		delete(self.data, name)
+		self.changed = true
+	}
+}
+
+// Returns all the session values.
+func (self *Session) Data() Data {
+	return self.data
+}
+
+// Assigns a new ID to the session and keeps its values. It should be called
+// whenever the privileges of a user change (e.g. on login) to prevent session
+// fixation.
+func (self *Session) Regenerate() error {
+	id, err := NewId()
+
+	if err != nil {
+		return err
+	}
+
+	if self.isNew == false && self.oldId == "" {
+		self.oldId = self.id
+	}
+
+	self.id = id
+	self.changed = true
+
+	return nil
+}
+
+// Removes all the values and ends the session.
+func (self *Session) Destroy() {
+	self.data = Data{}
+	self.destroyed = true
+}
+
+// Returns true if the session needs to be saved, sessions that were just
+// started are only saved if a value was set.
+func (self *Session) Dirty() bool {
+	if self.unsaved == true {
+		return false
+	}
+	if self.destroyed == true {
+		return self.isNew == false || self.oldId != ""
+	}
+	return self.changed == true || self.isNew == false
+}
+
+// Writes the session into its store, existing sessions are saved on every
+// request to extend their lifetime.
+func (self *Session) Save() error {
+	if self.unsaved == true {
+		return nil
+	}
+
+	if self.oldId != "" {
+		if err := self.store.Delete(self.oldId); err != nil {
+			return err
+		}
+		self.oldId = ""
+	}
+
+	if self.destroyed == true {
+		return self.store.Delete(self.id)
+	}
+
+	if self.Dirty() == false {
+		return nil
+	}
+
+	err := self.store.Set(self.id, self.data, self.lifetime)
+
+	if err == nil {
+		self.isNew = false
+		self.changed = false
+	}
+
+	return err
+}
@@ -0,0 +1,58 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"github.com/astrata/tango/token"
+	"sync"
+	"time"
+)
+
+var (
+	tokenSigner     *token.Signer
+	tokenSignerOnce sync.Once
+)
+
+// Returns a signer for the keys listed in security/keys.
+func configSigner() *token.Signer {
+	tokenSignerOnce.Do(func() {
+		tokenSigner = &token.Signer{Keys: secretKeys()}
+	})
+	return tokenSigner
+}
+
+// Like token.Signer.Sign(), with the keys from security/keys in
+// settings.yaml.
+func SignToken(purpose string, subject string, lifetime time.Duration, bind ...string) (string, error) {
+	return configSigner().Sign(purpose, subject, lifetime, bind...)
+}
+
+// Like token.Signer.Verify(), with the keys from security/keys in
+// settings.yaml.
+func VerifyToken(value string, purpose string, bind ...string) (string, error) {
+	return configSigner().Verify(value, purpose, bind...)
+}
@@ -0,0 +1,630 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package tango
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/astrata/tango/config"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types (RFC 6455 opcodes).
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket close codes.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseInvalidPayload  = 1007
+	CloseTooBig          = 1009
+)
+
+// Maximum size of an incoming message, in bytes.
+var SocketMaxMessageSize = 1 << 20
+
+// Interval between pings sent by the server. A socket that does not send
+// anything (not even a pong) for twice this interval is considered dead.
+var SocketPingInterval = 30 * time.Second
+
+// Time allowed to write a single message.
+var SocketWriteTimeout = 10 * time.Second
+
+// Returned by Socket.Read() after the connection was closed.
+var ErrSocketClosed = errors.New("Socket was closed.")
+
+const socketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var socketType = reflect.TypeOf(&Socket{})
+
+// A WebSocket connection. Model methods receive one when they have a
+// *tango.Socket argument, e.g.:
+//
+//	func (self *Chat) Room(socket *tango.Socket, name string) {
+//		for {
+//			message, err := socket.ReadText()
+//			...
+//		}
+//	}
+//
+// The socket is closed when the method returns.
+type Socket struct {
+	// Context of the request that opened the socket.
+	Context *Context
+
+	conn   net.Conn
+	reader *bufio.Reader
+
+	wmu sync.Mutex
+	mu  sync.Mutex
+
+	hubs   map[*Hub]bool
+	closed bool
+	done   chan bool
+}
+
+// Returns true if the method takes a *Socket argument.
+func acceptsSocket(t reflect.Type) bool {
+	for i := 0; i < t.NumIn(); i++ {
+		if t.In(i) == socketType {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the Origin header against the request host or the
+// websocket/origins list in settings.yaml.
+func (context *Context) checkOrigin() bool {
+	origin := context.Request.Header.Get("Origin")
+
+	if origin == "" {
+		// Not a browser.
+		return true
+	}
+
+	u, err := url.Parse(origin)
+
+	if err != nil {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, context.Host()) {
+		return true
+	}
+
+	for _, allowed := range config.Strings("websocket/origins") {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if a comma separated header contains the given token.
+func headerHasToken(header http.Header, name string, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Performs the WebSocket handshake and takes over the connection.
+func (context *Context) upgrade() (*Socket, error) {
+	req := context.Request
+
+	if req.Method != "GET" {
+		return nil, fmt.Errorf("WebSocket handshakes must use GET.")
+	}
+
+	if headerHasToken(req.Header, "Connection", "upgrade") == false || headerHasToken(req.Header, "Upgrade", "websocket") == false {
+		return nil, fmt.Errorf("Not a WebSocket handshake.")
+	}
+
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		context.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("Unsupported WebSocket version.")
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("Invalid Sec-WebSocket-Key.")
+	}
+
+	if context.checkOrigin() == false {
+		return nil, fmt.Errorf("Origin %s is not allowed.", req.Header.Get("Origin"))
+	}
+
+	hijacker, ok := context.Writer.(http.Hijacker)
+
+	if ok == false {
+		return nil, fmt.Errorf("This server does not support WebSockets.")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + socketGUID))
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+
+	conn.SetWriteDeadline(time.Now().Add(SocketWriteTimeout))
+
+	if _, err = conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	socket := &Socket{}
+	socket.Context = context
+	socket.conn = conn
+	socket.reader = rw.Reader
+	socket.hubs = make(map[*Hub]bool)
+	socket.done = make(chan bool)
+
+	socket.extendDeadline()
+
+	if SocketPingInterval > 0 {
+		go socket.keepAlive()
+	}
+
+	return socket, nil
+}
+
+// Sends pings until the socket is closed.
+func (socket *Socket) keepAlive() {
+	ticker := time.NewTicker(SocketPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if socket.Ping(nil) != nil {
+				return
+			}
+		case <-socket.done:
+			return
+		}
+	}
+}
+
+// Gives the client more time to send something.
+func (socket *Socket) extendDeadline() {
+	if SocketPingInterval > 0 {
+		socket.conn.SetReadDeadline(time.Now().Add(2 * SocketPingInterval))
+	}
+}
+
+// Reads a single frame.
+func (socket *Socket) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	head := make([]byte, 2)
+
+	if _, err = io.ReadFull(socket.reader, head); err != nil {
+		return
+	}
+
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+
+	if head[0]&0x70 != 0 {
+		err = socketError(CloseProtocolError, "Unexpected reserved bits.")
+		return
+	}
+
+	if head[1]&0x80 == 0 {
+		err = socketError(CloseProtocolError, "Client frames must be masked.")
+		return
+	}
+
+	length := uint64(head[1] & 0x7f)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(socket.reader, ext); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(socket.reader, ext); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if opcode >= CloseMessage && (length > 125 || fin == false) {
+		err = socketError(CloseProtocolError, "Invalid control frame.")
+		return
+	}
+
+	if length > uint64(SocketMaxMessageSize) {
+		err = socketError(CloseTooBig, "Message is too big.")
+		return
+	}
+
+	mask := make([]byte, 4)
+
+	if _, err = io.ReadFull(socket.reader, mask); err != nil {
+		return
+	}
+
+	payload = make([]byte, length)
+
+	if _, err = io.ReadFull(socket.reader, payload); err != nil {
+		return
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	socket.extendDeadline()
+
+	return
+}
+
+// Writes a single, unfragmented frame.
+func (socket *Socket) writeFrame(opcode int, payload []byte) error {
+	socket.wmu.Lock()
+	defer socket.wmu.Unlock()
+
+	frame := []byte{0x80 | byte(opcode)}
+
+	length := len(payload)
+
+	switch {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+
+	frame = append(frame, payload...)
+
+	socket.conn.SetWriteDeadline(time.Now().Add(SocketWriteTimeout))
+
+	_, err := socket.conn.Write(frame)
+
+	return err
+}
+
+// A protocol error that closes the socket with the given code.
+type SocketError struct {
+	Code   int
+	Reason string
+}
+
+func (e *SocketError) Error() string {
+	return fmt.Sprintf("WebSocket error %d: %s", e.Code, e.Reason)
+}
+
+func socketError(code int, reason string) error {
+	return &SocketError{code, reason}
+}
+
+// Reads the next text or binary message, answering pings and close frames
+// on the way. It returns ErrSocketClosed once the connection was closed.
+func (socket *Socket) Read() (int, []byte, error) {
+	var messageType int
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := socket.readFrame()
+
+		if err != nil {
+			closed := socket.isClosed()
+			if serr, ok := err.(*SocketError); ok {
+				socket.CloseWith(serr.Code, serr.Reason)
+			} else {
+				socket.shutdown()
+			}
+			if closed == true || err == io.EOF || errors.Is(err, net.ErrClosed) {
+				return 0, nil, ErrSocketClosed
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := socket.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+		case PongMessage:
+			// Deadline was already extended.
+		case CloseMessage:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			socket.CloseWith(code, "")
+			return 0, nil, ErrSocketClosed
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				socket.CloseWith(CloseProtocolError, "Expecting a continuation frame.")
+				return 0, nil, ErrSocketClosed
+			}
+			messageType = opcode
+			message = payload
+		case 0:
+			if messageType == 0 {
+				socket.CloseWith(CloseProtocolError, "Unexpected continuation frame.")
+				return 0, nil, ErrSocketClosed
+			}
+			if len(message)+len(payload) > SocketMaxMessageSize {
+				socket.CloseWith(CloseTooBig, "Message is too big.")
+				return 0, nil, ErrSocketClosed
+			}
+			message = append(message, payload...)
+		default:
+			socket.CloseWith(CloseProtocolError, "Unknown opcode.")
+			return 0, nil, ErrSocketClosed
+		}
+
+		if messageType != 0 && fin == true {
+			if messageType == TextMessage && utf8.Valid(message) == false {
+				socket.CloseWith(CloseInvalidPayload, "Invalid UTF-8.")
+				return 0, nil, ErrSocketClosed
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// Reads the next message as a string.
+func (socket *Socket) ReadText() (string, error) {
+	_, message, err := socket.Read()
+	return string(message), err
+}
+
+// Reads the next message and decodes it as JSON into v.
+func (socket *Socket) ReadJSON(v interface{}) error {
+	_, message, err := socket.Read()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(message, v)
+}
+
+// Sends a text or binary message.
+func (socket *Socket) Write(messageType int, message []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("Unsupported message type %d.", messageType)
+	}
+	if socket.isClosed() == true {
+		return ErrSocketClosed
+	}
+	return socket.writeFrame(messageType, message)
+}
+
+// Sends a text message.
+func (socket *Socket) WriteText(message string) error {
+	return socket.Write(TextMessage, []byte(message))
+}
+
+// Encodes v as JSON and sends it as a text message.
+func (socket *Socket) WriteJSON(v interface{}) error {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return socket.Write(TextMessage, message)
+}
+
+// Sends a ping, the client answers with a pong.
+func (socket *Socket) Ping(data []byte) error {
+	if socket.isClosed() == true {
+		return ErrSocketClosed
+	}
+	return socket.writeFrame(PingMessage, data)
+}
+
+// Closes the socket normally.
+func (socket *Socket) Close() error {
+	return socket.CloseWith(CloseNormal, "")
+}
+
+// Sends a close frame with the given code and reason and closes the
+// connection.
+func (socket *Socket) CloseWith(code int, reason string) error {
+	if socket.isClosed() == true {
+		return nil
+	}
+
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	err := socket.writeFrame(CloseMessage, payload)
+
+	socket.shutdown()
+
+	return err
+}
+
+// Returns true if the socket was closed.
+func (socket *Socket) isClosed() bool {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+	return socket.closed
+}
+
+// Closes the connection and leaves every hub.
+func (socket *Socket) shutdown() {
+	socket.mu.Lock()
+
+	if socket.closed == true {
+		socket.mu.Unlock()
+		return
+	}
+
+	socket.closed = true
+	close(socket.done)
+
+	hubs := socket.hubs
+	socket.hubs = make(map[*Hub]bool)
+
+	socket.mu.Unlock()
+
+	for hub := range hubs {
+		hub.Leave(socket)
+	}
+
+	socket.conn.Close()
+}
+
+// Registers the socket with a hub, it leaves it automatically when closed.
+func (socket *Socket) Join(hub *Hub) {
+	socket.mu.Lock()
+	defer socket.mu.Unlock()
+
+	if socket.closed == true {
+		return
+	}
+
+	socket.hubs[hub] = true
+
+	// Still holding the socket lock, so shutdown() can't run in between and
+	// leave a closed socket in the hub.
+	hub.mu.Lock()
+	hub.sockets[socket] = true
+	hub.mu.Unlock()
+}
+
+// A set of sockets that can receive the same messages.
+type Hub struct {
+	mu      sync.Mutex
+	sockets map[*Socket]bool
+}
+
+// Allocates a new &Hub{}.
+func NewHub() *Hub {
+	hub := &Hub{}
+	hub.sockets = make(map[*Socket]bool)
+	return hub
+}
+
+// Removes a socket from the hub.
+func (hub *Hub) Leave(socket *Socket) {
+	hub.mu.Lock()
+	delete(hub.sockets, socket)
+	hub.mu.Unlock()
+
+	socket.mu.Lock()
+	delete(socket.hubs, hub)
+	socket.mu.Unlock()
+}
+
+// Returns the number of sockets in the hub.
+func (hub *Hub) Len() int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return len(hub.sockets)
+}
+
+// Sends a message to every socket in the hub at the same time, so a stalled
+// client does not delay the others, and returns once every socket got it.
+// Sockets that fail to receive it are closed and leave the hub.
+func (hub *Hub) Broadcast(messageType int, message []byte) {
+	hub.mu.Lock()
+	sockets := make([]*Socket, 0, len(hub.sockets))
+	for socket := range hub.sockets {
+		sockets = append(sockets, socket)
+	}
+	hub.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for _, socket := range sockets {
+		wg.Add(1)
+		go func(socket *Socket) {
+			defer wg.Done()
+			if err := socket.Write(messageType, message); err != nil {
+				socket.shutdown()
+				hub.Leave(socket)
+			}
+		}(socket)
+	}
+
+	wg.Wait()
+}
+
+// Sends a text message to every socket in the hub.
+func (hub *Hub) BroadcastText(message string) {
+	hub.Broadcast(TextMessage, []byte(message))
+}
+
+// Encodes v as JSON and sends it to every socket in the hub.
+func (hub *Hub) BroadcastJSON(v interface{}) error {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	hub.Broadcast(TextMessage, message)
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Returned when a signed token can't be used.
+type Error struct {
+	// True if the token is authentic but has expired.
+	Expired bool
+	Reason  string
+}
+
+func (e *Error) Error() string {
+	return e.Reason
+}
+
+// Returns a new error for a token that can't be used.
+func errInvalid() error {
+	return &Error{false, "Invalid token."}
+}
+
+// Returns a new error for an authentic token that has expired.
+func errExpired() error {
+	return &Error{true, "Token has expired."}
+}
+
+// Signed token contents.
+type claims struct {
+	Purpose string `json:"p"`
+	Subject string `json:"s"`
+	Expires int64  `json:"e"`
+}
+
+// Creates and verifies self-contained tokens, like the ones sent in password
+// reset or e-mail verification links.
+type Signer struct {
+	// HMAC keys, newest first. Tokens are signed with the first key and
+	// verified with any of them.
+	Keys [][]byte
+}
+
+// Computes the signature of a payload.
+func (self *Signer) mac(key []byte, payload string, bind []string) []byte {
+	// The key is derived so it can't be used for anything else.
+	derived := hmac.New(sha256.New, key)
+	derived.Write([]byte("tango signed token"))
+
+	mac := hmac.New(sha256.New, derived.Sum(nil))
+	mac.Write([]byte(payload))
+
+	// Every value is prefixed with its length, so different lists can't
+	// produce the same input.
+	size := make([]byte, 8)
+
+	for _, value := range bind {
+		binary.BigEndian.PutUint64(size, uint64(len(value)))
+		mac.Write(size)
+		mac.Write([]byte(value))
+	}
+
+	return mac.Sum(nil)
+}
+
+// Returns a token that says subject may do purpose until lifetime passes.
+// The token can be bound to values it does not contain (e.g. the current
+// password hash of the user), it stops being valid when they change.
+func (self *Signer) Sign(purpose string, subject string, lifetime time.Duration, bind ...string) (string, error) {
+	if len(self.Keys) == 0 {
+		return "", &Error{false, "No keys to sign tokens with."}
+	}
+
+	data, err := json.Marshal(claims{purpose, subject, time.Now().Add(lifetime).Unix()})
+
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(self.mac(self.Keys[0], payload, bind)), nil
+}
+
+// Checks a token for the given purpose and bound values and returns its
+// subject. The error is an *Error.
+func (self *Signer) Verify(token string, purpose string, bind ...string) (string, error) {
+	chunks := strings.Split(token, ".")
+
+	if len(chunks) != 2 {
+		return "", errInvalid()
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(chunks[1])
+
+	if err != nil {
+		return "", errInvalid()
+	}
+
+	verified := false
+
+	for _, key := range self.Keys {
+		if hmac.Equal(self.mac(key, chunks[0], bind), signature) == true {
+			verified = true
+			break
+		}
+	}
+
+	if verified == false {
+		return "", errInvalid()
+	}
+
+	c, err := decodeClaims(chunks[0])
+
+	if err != nil || c.Purpose != purpose {
+		return "", errInvalid()
+	}
+
+	if time.Now().Unix() > c.Expires {
+		return "", errExpired()
+	}
+
+	return c.Subject, nil
+}
+
+// Returns the subject of a token without verifying it, so the values it was
+// bound to can be looked up before calling Verify().
+func Subject(token string) (string, error) {
+	c, err := decodeClaims(strings.SplitN(token, ".", 2)[0])
+
+	if err != nil {
+		return "", errInvalid()
+	}
+
+	return c.Subject, nil
+}
+
+func decodeClaims(payload string) (*claims, error) {
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &claims{}
+
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,135 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	oldKey := []byte("old key")
+	newKey := []byte("new key")
+
+	signer := &Signer{Keys: [][]byte{oldKey}}
+
+	sign := func(purpose string, lifetime time.Duration, bind ...string) string {
+		token, err := signer.Sign(purpose, "user@example.com", lifetime, bind...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	valid := sign("reset", time.Hour)
+
+	tampered := []byte(valid)
+	tampered[2] ^= 1
+
+	forged := []byte(valid)
+	forged[len(forged)-3] ^= 1
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		token   string
+		purpose string
+		bind    []string
+		expired bool
+		err     bool
+	}{
+		{"valid", signer, valid, "reset", nil, false, false},
+		{"rotated key", &Signer{Keys: [][]byte{newKey, oldKey}}, valid, "reset", nil, false, false},
+		{"retired key", &Signer{Keys: [][]byte{newKey}}, valid, "reset", nil, false, true},
+		{"other purpose", signer, valid, "verify", nil, false, true},
+		{"expired", signer, sign("reset", -2*time.Second), "reset", nil, true, true},
+		{"tampered payload", signer, string(tampered), "reset", nil, false, true},
+		{"tampered signature", signer, string(forged), "reset", nil, false, true},
+		{"no signature", signer, strings.Split(valid, ".")[0], "reset", nil, false, true},
+		{"garbage", signer, "a.b.c", "reset", nil, false, true},
+		{"bound", signer, sign("reset", time.Hour, "hash"), "reset", []string{"hash"}, false, false},
+		{"bound value changed", signer, sign("reset", time.Hour, "hash"), "reset", []string{"other"}, false, true},
+		{"bound value missing", signer, sign("reset", time.Hour, "hash"), "reset", nil, false, true},
+		{"bound values joined", signer, sign("reset", time.Hour, "a\x00\x00\x00\x00\x00\x00\x00\x01b"), "reset", []string{"a", "b"}, false, true},
+		{"bound values split", signer, sign("reset", time.Hour, "a", "b"), "reset", []string{"ab"}, false, true},
+	}
+
+	for _, test := range tests {
+		subject, err := test.signer.Verify(test.token, test.purpose, test.bind...)
+
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v.", test.name, err)
+			continue
+		}
+
+		if err != nil {
+			e, ok := err.(*Error)
+			if ok == false || e.Expired != test.expired {
+				t.Errorf("%s: got %#v, expecting an *Error with Expired %v.", test.name, err, test.expired)
+			}
+			continue
+		}
+
+		if subject != "user@example.com" {
+			t.Errorf("%s: got subject %q.", test.name, subject)
+		}
+	}
+}
+
+func TestSignerErrors(t *testing.T) {
+	if _, err := (&Signer{}).Sign("reset", "user", time.Hour); err == nil {
+		t.Fatalf("Expecting an error without keys.")
+	}
+
+	// Errors are not shared, callers may keep them.
+	signer := &Signer{Keys: [][]byte{[]byte("key")}}
+
+	_, a := signer.Verify("x", "reset")
+	_, b := signer.Verify("y", "reset")
+
+	if a == b {
+		t.Fatalf("Expecting distinct errors.")
+	}
+}
+
+func TestSubject(t *testing.T) {
+	signer := &Signer{Keys: [][]byte{[]byte("key")}}
+
+	token, err := signer.Sign("reset", "user", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject, err := Subject(token); err != nil || subject != "user" {
+		t.Fatalf("Got %q (%v), expecting %q.", subject, err, "user")
+	}
+
+	if _, err := Subject("!"); err == nil {
+		t.Fatalf("Expecting an error for a malformed token.")
+	}
+}
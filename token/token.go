@@ -24,36 +24,166 @@
   WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 */
 
+/*
+  This package generates random tokens. Every function uses crypto/rand, so
+  tokens are safe to use as secrets.
+*/
 package token
 
 import (
-	"bytes"
-	"math/rand"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"time"
+	"unicode/utf8"
 )
 
-// Generates a token with a given charset and length.
-func Generate(charset string, length int) string {
-	var buffer bytes.Buffer
+const (
+	// Letters and digits.
+	AlphaCharset = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// Characters that are hard to mistake for one another.
+	HumanCharset = "ABCDEFGHJKMPRTWXY234789"
+)
+
+// Returns n random bytes, or none if n is not positive.
+func Bytes(n int) ([]byte, error) {
+	if n < 1 {
+		return []byte{}, nil
+	}
+
+	buf := make([]byte, n)
+
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// Returns a string of the given length made of characters picked at random
+// from charset, every character has the same probability. A length below 1
+// yields an empty string.
+func Random(charset string, length int) (string, error) {
+	chars := []rune(charset)
 
-	rand.Seed(time.Now().UTC().UnixNano())
+	clen := len(chars)
 
-	clen := len(charset)
+	if clen == 0 || clen > 256 || utf8.ValidString(charset) == false {
+		return "", fmt.Errorf("Charset must have between 1 and 256 characters.")
+	}
+
+	if length < 1 {
+		return "", nil
+	}
+
+	// Bytes above the largest multiple of clen are discarded, otherwise the
+	// first characters of the charset would be more likely.
+	limit := 256 - (256 % clen)
+
+	result := make([]rune, 0, length)
+
+	buf := make([]byte, length+length/2+8)
+
+	for len(result) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit {
+				result = append(result, chars[int(b)%clen])
+				if len(result) == length {
+					break
+				}
+			}
+		}
+	}
+
+	return string(result), nil
+}
+
+// Generates a token with a given charset and length. It panics if the
+// charset is empty, longer than 256 characters or not valid UTF-8, and if the
+// system's random source fails, use Random() to get an error instead.
+func Generate(charset string, length int) string {
+	token, err := Random(charset, length)
 
-	for i := 0; i < length; i++ {
-		offset := rand.Intn(clen)
-		buffer.WriteString(charset[offset : offset+1])
+	if err != nil {
+		panic(err.Error())
 	}
 
-	return buffer.String()
+	return token
 }
 
 // Standard token of arbitrary length.
 func Alpha(length int) string {
-	return Generate("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", length)
+	return Generate(AlphaCharset, length)
 }
 
 // A token that is easily typable by humans.
 func Human(length int) string {
-	return Generate("ABCDEFGHJKMPRTWXY234789", length)
+	return Generate(HumanCharset, length)
+}
+
+// Returns n random bytes encoded as URL-safe base64, without padding.
+func URLSafe(n int) (string, error) {
+	buf, err := Bytes(n)
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Formats 16 bytes as a UUID.
+func formatUUID(u []byte) string {
+	buf := make([]byte, 36)
+
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf)
+}
+
+// Returns a random UUID (version 4).
+func UUID4() (string, error) {
+	u, err := Bytes(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return formatUUID(u), nil
+}
+
+// Returns a time-ordered UUID (version 7), UUIDs generated within the same
+// millisecond are not ordered among themselves.
+func UUID7() (string, error) {
+	u, err := Bytes(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	ms := make([]byte, 8)
+	binary.BigEndian.PutUint64(ms, uint64(time.Now().UnixNano()/int64(time.Millisecond)))
+
+	copy(u[0:6], ms[2:8])
+
+	u[6] = (u[6] & 0x0f) | 0x70
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return formatUUID(u), nil
 }
@@ -0,0 +1,70 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package token
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRandom(t *testing.T) {
+	tests := []struct {
+		charset string
+		length  int
+		want    int
+		err     bool
+	}{
+		{AlphaCharset, 16, 16, false},
+		{HumanCharset, 1, 1, false},
+		{AlphaCharset, 0, 0, false},
+		{AlphaCharset, -5, 0, false},
+		{"", 8, 0, true},
+		{strings.Repeat("a", 257), 8, 0, true},
+		{"\xff", 8, 0, true},
+	}
+
+	for _, test := range tests {
+		token, err := Random(test.charset, test.length)
+		if (err != nil) != test.err {
+			t.Fatalf("Random(%q, %d): unexpected error %v.", test.charset, test.length, err)
+		}
+		if len([]rune(token)) != test.want {
+			t.Fatalf("Random(%q, %d): got %d characters, expecting %d.", test.charset, test.length, len(token), test.want)
+		}
+		for _, c := range token {
+			if strings.ContainsRune(test.charset, c) == false {
+				t.Fatalf("Random(%q, %d): unexpected character %q.", test.charset, test.length, c)
+			}
+		}
+	}
+}
+
+func TestAlphaNegative(t *testing.T) {
+	if Alpha(-1) != "" {
+		t.Fatalf("Expecting an empty token.")
+	}
+}
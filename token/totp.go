@@ -0,0 +1,243 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var totpHashes = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// Time-based one-time passwords (RFC 6238).
+type TOTP struct {
+	// Shared secret, base32 encoded.
+	Secret string
+	// Number of digits of a code, 6 if not set and at most 10.
+	Digits int
+	// Time a code is valid, whole seconds. 30 seconds if not set or shorter
+	// than a second.
+	Period time.Duration
+	// Number of periods before and after the current one whose codes are
+	// also accepted, to tolerate clock differences.
+	Skew int
+	// "SHA1", "SHA256" or "SHA512", SHA1 if not set. Most authenticator
+	// apps only support SHA1.
+	Algorithm string
+}
+
+// Default TOTP settings.
+const (
+	defaultTOTPDigits    = 6
+	defaultTOTPPeriod    = 30 * time.Second
+	defaultTOTPAlgorithm = "SHA1"
+)
+
+// Returns a new random TOTP secret, base32 encoded.
+func NewTOTPSecret() (string, error) {
+	buf, err := Bytes(20)
+
+	if err != nil {
+		return "", err
+	}
+
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// Returns a TOTP with the settings authenticator apps expect: 6 digits,
+// 30 seconds, SHA1 and a skew of one period.
+func NewTOTP(secret string) *TOTP {
+	return &TOTP{Secret: secret, Digits: defaultTOTPDigits, Period: defaultTOTPPeriod, Skew: 1, Algorithm: defaultTOTPAlgorithm}
+}
+
+func (self *TOTP) digits() int {
+	switch {
+	case self.Digits < 1:
+		return defaultTOTPDigits
+	case self.Digits > 10:
+		// Codes come from a 31 bit number.
+		return 10
+	}
+	return self.Digits
+}
+
+// Returns the period in seconds.
+func (self *TOTP) period() int64 {
+	if self.Period < time.Second {
+		return int64(defaultTOTPPeriod / time.Second)
+	}
+	return int64(self.Period / time.Second)
+}
+
+func (self *TOTP) algorithm() string {
+	if self.Algorithm == "" {
+		return defaultTOTPAlgorithm
+	}
+	return self.Algorithm
+}
+
+func (self *TOTP) key() ([]byte, error) {
+	secret := strings.ToUpper(strings.Replace(self.Secret, " ", "", -1))
+	return totpEncoding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// Returns the time step for the given time.
+func (self *TOTP) Counter(at time.Time) int64 {
+	return at.Unix() / self.period()
+}
+
+// Returns the code for a time step (RFC 4226).
+func (self *TOTP) code(key []byte, counter int64) (string, error) {
+	newHash, ok := totpHashes[self.algorithm()]
+
+	if ok == false {
+		return "", fmt.Errorf("Unsupported algorithm %s.", self.Algorithm)
+	}
+
+	digits := self.digits()
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(newHash, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+
+	value := int64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+
+	mod := int64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod), nil
+}
+
+// Returns the code for the given time.
+func (self *TOTP) Code(at time.Time) (string, error) {
+	key, err := self.key()
+
+	if err != nil {
+		return "", err
+	}
+
+	return self.code(key, self.Counter(at))
+}
+
+// Checks a code against the current time, see VerifyAt().
+func (self *TOTP) Verify(code string, last int64) (int64, bool) {
+	return self.VerifyAt(code, time.Now(), last)
+}
+
+// Checks a code and returns the time step it belongs to. Codes for steps up
+// to last are rejected so a code can't be used twice: store the returned
+// step and pass it as last on the next verification (0 at first).
+func (self *TOTP) VerifyAt(code string, at time.Time, last int64) (int64, bool) {
+	key, err := self.key()
+
+	if err != nil || len(code) != self.digits() {
+		return 0, false
+	}
+
+	current := self.Counter(at)
+
+	for i := -self.Skew; i <= self.Skew; i++ {
+		counter := current + int64(i)
+
+		if counter <= last {
+			continue
+		}
+
+		expected, err := self.code(key, counter)
+
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// Returns an otpauth:// URI for provisioning authenticator apps (usually
+// shown as a QR code).
+func (self *TOTP) URI(issuer string, account string) string {
+	params := url.Values{}
+	params.Set("secret", strings.TrimRight(strings.ToUpper(self.Secret), "="))
+	params.Set("algorithm", self.algorithm())
+	params.Set("digits", fmt.Sprintf("%d", self.digits()))
+	params.Set("period", fmt.Sprintf("%d", self.period()))
+
+	label := url.PathEscape(account)
+
+	if issuer != "" {
+		params.Set("issuer", issuer)
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Returns n single-use recovery codes made of HumanCharset characters.
+func RecoveryCodes(n int, length int) ([]string, error) {
+	if n < 1 || length < 1 {
+		return nil, fmt.Errorf("Expecting at least one recovery code of at least one character.")
+	}
+
+	codes := make([]string, n)
+
+	for i := range codes {
+		code, err := Random(HumanCharset, length)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,180 @@
+/*
+  Tango!
+
+  Copyright (c) 2012 Astrata Software, <http://astrata.mx>
+  Written by José Carlos Nieto <xiam@menteslibres.org>
+
+  Permission is hereby granted, free of charge, to any person obtaining
+  a copy of this software and associated documentation files (the
+  "Software"), to deal in the Software without restriction, including
+  without limitation the rights to use, copy, modify, merge, publish,
+  distribute, sublicense, and/or sell copies of the Software, and to
+  permit persons to whom the Software is furnished to do so, subject to
+  the following conditions:
+
+  The above copyright notice and this permission notice shall be
+  included in all copies or substantial portions of the Software.
+
+  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+  EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+  MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+  NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+  LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+  OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+  WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package token
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 6238, Appendix B.
+func TestTOTPVectors(t *testing.T) {
+	secrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	vectors := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, v := range vectors {
+		totp := &TOTP{
+			Secret:    base32.StdEncoding.EncodeToString([]byte(secrets[v.algorithm])),
+			Digits:    8,
+			Period:    30 * time.Second,
+			Algorithm: v.algorithm,
+		}
+
+		code, err := totp.Code(time.Unix(v.unix, 0))
+
+		if err != nil {
+			t.Fatalf("%d %s: %s", v.unix, v.algorithm, err)
+		}
+
+		if code != v.code {
+			t.Errorf("%d %s: expected %s, got %s", v.unix, v.algorithm, v.code, code)
+		}
+
+		if _, ok := totp.VerifyAt(v.code, time.Unix(v.unix, 0), 0); ok == false {
+			t.Errorf("%d %s: %s was not accepted", v.unix, v.algorithm, v.code)
+		}
+	}
+}
+
+func TestTOTPVerify(t *testing.T) {
+	secret, err := NewTOTPSecret()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totp := NewTOTP(secret)
+	now := time.Unix(1400000000, 0)
+
+	code, _ := totp.Code(now)
+
+	counter, ok := totp.VerifyAt(code, now, 0)
+
+	if ok == false || counter != totp.Counter(now) {
+		t.Fatalf("Expected the current code to be accepted.")
+	}
+
+	if _, ok := totp.VerifyAt(code, now, counter); ok == true {
+		t.Errorf("Expected a used code to be rejected.")
+	}
+
+	previous, _ := totp.Code(now.Add(-30 * time.Second))
+
+	if _, ok := totp.VerifyAt(previous, now, 0); ok == false {
+		t.Errorf("Expected the previous code to be accepted within the skew.")
+	}
+
+	old, _ := totp.Code(now.Add(-90 * time.Second))
+
+	if _, ok := totp.VerifyAt(old, now, 0); ok == true {
+		t.Errorf("Expected a code outside the skew to be rejected.")
+	}
+
+	if _, ok := totp.VerifyAt("12345", now, 0); ok == true {
+		t.Errorf("Expected a short code to be rejected.")
+	}
+}
+
+func TestTOTPDefaults(t *testing.T) {
+	totp := &TOTP{Secret: "JBSWY3DPEHPK3PXP", Period: 500 * time.Millisecond}
+
+	code, err := totp.Code(time.Unix(59, 0))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(code) != 6 {
+		t.Errorf("Expected 6 digits, got %q.", code)
+	}
+
+	if totp.Counter(time.Unix(59, 0)) != 1 {
+		t.Errorf("Expected a 30 second period.")
+	}
+
+	uri := totp.URI("Acme", "user@example.com")
+
+	if strings.Contains(uri, "period=30") == false || strings.Contains(uri, "algorithm=SHA1") == false {
+		t.Errorf("Unexpected URI %s.", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := RecoveryCodes(10, 12)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+
+	for _, code := range codes {
+		if len(code) != 12 || strings.Trim(code, HumanCharset) != "" {
+			t.Errorf("Unexpected recovery code %q.", code)
+		}
+		if seen[code] {
+			t.Errorf("Repeated recovery code %q.", code)
+		}
+		seen[code] = true
+	}
+
+	for _, args := range [][2]int{{-1, 12}, {0, 12}, {10, 0}, {10, -1}} {
+		if _, err := RecoveryCodes(args[0], args[1]); err == nil {
+			t.Errorf("RecoveryCodes(%d, %d): expecting an error.", args[0], args[1])
+		}
+	}
+}
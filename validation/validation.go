@@ -3,8 +3,8 @@ package validation
 import (
 	"fmt"
 	"github.com/astrata/tango"
+	"github.com/astrata/tango/body"
 	"regexp"
-	"strconv"
 )
 
 // Validation function.
@@ -39,7 +39,7 @@ func (self *Rules) Validate(params tango.Value) (bool, map[string][]string) {
 	messages := map[string][]string{}
 
 	for key, _ := range params {
-		value := params.GetString(key)
+		value := params.Get(key)
 		if constraint, ok := self.Map[key]; ok == true {
 			errors := []string{}
 			passed := true
@@ -60,6 +60,21 @@ func (self *Rules) Validate(params tango.Value) (bool, map[string][]string) {
 	return valid, messages
 }
 
+// Validates input data and returns an application/problem+json body with
+// per-field errors, or nil if the data is valid.
+func (self *Rules) Problem(params tango.Value) body.Body {
+	valid, messages := self.Validate(params)
+
+	if valid == true {
+		return nil
+	}
+
+	problem := body.Problem()
+	problem.Set(messages)
+
+	return problem
+}
+
 func (self *Rules) Add(name string, rule Rule, message string) {
 	var constraint Constraint
 	var ok bool